@@ -0,0 +1,367 @@
+package bibtex
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Author is a personal name in an author or editor field, split into the
+// four parts recognised by BibTeX.
+type Author struct {
+	First string // First names, e.g. "Donald E."
+	Von   string // Lowercase particles, e.g. "van der"
+	Last  string // Last names, e.g. "Knuth"
+	Jr    string // Suffix, e.g. "Jr."
+}
+
+// Others is the placeholder BibTeX uses for a truncated author list, as in
+// "A. Author and others".
+const Others = "others"
+
+// IsOthers returns true if the author is the "others" placeholder.
+func (a Author) IsOthers() bool {
+	return a.First == "" && a.Von == "" && a.Jr == "" && a.Last == Others
+}
+
+// String returns the full name of the author, e.g. "Ludwig van Beethoven".
+func (a Author) String() string {
+	name := strings.Join(nonEmpty(a.First, a.Von, a.Last), " ")
+	if a.Jr != "" {
+		name += ", " + a.Jr
+	}
+	return name
+}
+
+// Initials returns the initials of the first names, e.g. "D. E." for
+// "Donald Ervin". Hyphenated names keep their hyphen, e.g. "J.-P.".
+func (a Author) Initials() string {
+	var initials []string
+	for _, word := range splitWords(a.First) {
+		var parts []string
+		for _, part := range strings.Split(word, "-") {
+			for _, r := range part {
+				if unicode.IsLetter(r) {
+					parts = append(parts, string(r)+".")
+					break
+				}
+			}
+		}
+		if len(parts) > 0 {
+			initials = append(initials, strings.Join(parts, "-"))
+		}
+	}
+	return strings.Join(initials, " ")
+}
+
+// NameStyle is a style for displaying an author name.
+type NameStyle int
+
+const (
+	// FullName displays names in full, e.g. "Donald E. Knuth".
+	FullName NameStyle = iota
+	// LastInitials displays names as last name and initials, e.g. "Knuth, D. E.".
+	LastInitials
+)
+
+// Format returns the name of the author in the given style.
+func (a Author) Format(style NameStyle) string {
+	if a.IsOthers() {
+		return a.Last
+	}
+	switch style {
+	case LastInitials:
+		name := strings.Join(nonEmpty(a.Von, a.Last), " ")
+		if a.Jr != "" {
+			name += ", " + a.Jr
+		}
+		if initials := a.Initials(); initials != "" {
+			name += ", " + initials
+		}
+		return name
+	default:
+		return a.String()
+	}
+}
+
+// FormatAuthors formats a list of authors for display. Lists longer than max
+// authors (or ending in "others") are shortened to "First Author et al.", two
+// authors are joined by "and", and longer lists are comma-separated with "and"
+// before the last author. A max of zero or less never shortens the list.
+func FormatAuthors(authors []Author, max int, style NameStyle) string {
+	if len(authors) == 0 {
+		return ""
+	}
+	if (max > 0 && len(authors) > max) || (len(authors) > 1 && authors[len(authors)-1].IsOthers()) {
+		return authors[0].Format(style) + " et al."
+	}
+	names := make([]string, len(authors))
+	for i, author := range authors {
+		names[i] = author.Format(style)
+	}
+	switch len(names) {
+	case 1:
+		return names[0]
+	case 2:
+		return names[0] + " and " + names[1]
+	default:
+		return strings.Join(names[:len(names)-1], ", ") + ", and " + names[len(names)-1]
+	}
+}
+
+// ParseAuthors parses a list of names separated by "and", as found in the
+// author and editor fields. Braced groups are never split.
+func ParseAuthors(s string) ([]Author, error) {
+	var authors []Author
+	for _, name := range splitNames(s) {
+		author, err := ParseAuthor(name)
+		if err != nil {
+			return nil, err
+		}
+		authors = append(authors, author)
+	}
+	return authors, nil
+}
+
+// ParseAuthor parses a single name in one of the three BibTeX forms:
+//
+//	First von Last
+//	von Last, First
+//	von Last, Jr, First
+func ParseAuthor(name string) (Author, error) {
+	var parts [][]string
+	for _, part := range splitTopLevel(name, ",") {
+		parts = append(parts, splitWords(part))
+	}
+	if len(parts) == 0 || len(parts[0]) == 0 {
+		return Author{}, fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	var author Author
+	switch len(parts) {
+	case 1: // First von Last
+		words := parts[0]
+		last := len(words) - 1
+		von := -1
+		for i := 0; i < last; i++ {
+			if isVonWord(words[i]) {
+				if von < 0 {
+					von = i
+				}
+			} else if von >= 0 {
+				last = i // Capitalised word after von starts Last.
+				break
+			}
+		}
+		switch {
+		case von >= 0:
+			author.First = strings.Join(words[:von], " ")
+			author.Von = strings.Join(words[von:last], " ")
+			author.Last = strings.Join(words[last:], " ")
+		default:
+			author.First = strings.Join(words[:last], " ")
+			author.Last = words[last]
+		}
+	case 2, 3: // von Last, [Jr,] First
+		author.Von, author.Last = splitVonLast(parts[0])
+		author.First = strings.Join(parts[len(parts)-1], " ")
+		if len(parts) == 3 {
+			author.Jr = strings.Join(parts[1], " ")
+		}
+	default:
+		return Author{}, fmt.Errorf("%w: too many commas in %q", ErrInvalidName, name)
+	}
+	return author, nil
+}
+
+// authorFields are the fields that contain lists of names.
+var authorFields = []string{"author", "editor"}
+
+// NormalizeAuthorSeparators rewrites author and editor fields that separate
+// names with semicolons or commas to use " and " as BibTeX expects, e.g.
+// "Knuth, Donald; Lamport, Leslie" becomes "Knuth, Donald and Lamport, Leslie".
+// Commas only separate names if the text cannot be a single "von Last, First"
+// or "von Last, Jr, First" name: every name around them must have at least two
+// words, and there must be at least three names or a comma before "and", so
+// "Alan Turing, Donald Knuth, Leslie Lamport" and "Donald Knuth, Leslie
+// Lamport, and Alan Turing" are split but "Garcia Marquez, Gabriel Jose" is
+// not. Fields that need no change are left as they are.
+func (bib *BibTex) NormalizeAuthorSeparators() {
+	for _, entry := range bib.Entries {
+		for _, field := range authorFields {
+			val, ok := entry.Fields[field]
+			if !ok {
+				continue
+			}
+			if names, changed := normalizeAuthorSeparators(val.String(), ";", true); changed {
+				entry.AddField(field, NewBibConst(names))
+			}
+		}
+	}
+}
+
+// NormalizeAuthorFields rewrites author and editor fields that separate names
+// with opts.AuthorSeparator (outside braces) to use " and ", and returns the
+// number of fields changed. Whitespace around the separator and the names is
+// ignored, and names may also be separated by "and". Fields that need no
+// change, and all fields with the default separator, are left as they are.
+func (bib *BibTex) NormalizeAuthorFields(opts ParseOptions) int {
+	sep := strings.TrimSpace(opts.AuthorSeparator)
+	if sep == "" || strings.EqualFold(sep, "and") {
+		return 0
+	}
+	changed := 0
+	for _, entry := range bib.Entries {
+		for _, field := range authorFields {
+			val, ok := entry.Fields[field]
+			if !ok {
+				continue
+			}
+			if names, ok := normalizeAuthorSeparators(val.String(), sep, false); ok {
+				entry.AddField(field, NewBibConst(names))
+				changed++
+			}
+		}
+	}
+	return changed
+}
+
+// normalizeAuthorSeparators splits the names in s on "and" and sep outside
+// braces, and on commas between full names if commas is set (see
+// splitCommaNames), and joins them with " and ". It returns false if the
+// number of names is unchanged.
+func normalizeAuthorSeparators(s, sep string, commas bool) (string, bool) {
+	chunks := splitNames(s)
+	var names []string
+	for _, chunk := range chunks {
+		for _, part := range splitTopLevel(chunk, sep) {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			if commas {
+				names = append(names, splitCommaNames(part)...)
+			} else {
+				names = append(names, part)
+			}
+		}
+	}
+	if len(names) == len(chunks) {
+		return s, false
+	}
+	return strings.Join(names, " and "), true
+}
+
+// splitCommaNames splits s on commas if it is a list of full names, i.e. all
+// comma-separated parts have at least two words and there are at least three
+// of them, or two followed by a trailing comma (the serial comma before "and").
+// Otherwise the commas are part of a "von Last, Jr, First" name and s is
+// returned as is.
+func splitCommaNames(s string) []string {
+	var parts []string
+	for _, part := range splitTopLevel(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			if len(splitWords(part)) < 2 {
+				return []string{s}
+			}
+			parts = append(parts, part)
+		}
+	}
+	serialComma := strings.HasSuffix(strings.TrimSpace(s), ",")
+	if len(parts) < 2 || (len(parts) == 2 && !serialComma) {
+		return []string{s}
+	}
+	return parts
+}
+
+// splitVonLast splits the words before the first comma into von and Last.
+// The last word is always part of Last.
+func splitVonLast(words []string) (von, last string) {
+	split := 0
+	for i := 0; i < len(words)-1; i++ {
+		if isVonWord(words[i]) {
+			split = i + 1
+		}
+	}
+	return strings.Join(words[:split], " "), strings.Join(words[split:], " ")
+}
+
+// isVonWord returns true if word starts with a lowercase letter at brace level
+// zero, which is how BibTeX identifies von particles.
+func isVonWord(word string) bool {
+	for _, r := range word {
+		if r == '{' {
+			return false
+		}
+		if unicode.IsLetter(r) {
+			return unicode.IsLower(r)
+		}
+	}
+	return false
+}
+
+// splitNames splits s on the word "and" (case-insensitive) outside braces.
+func splitNames(s string) []string {
+	var names []string
+	var name []string
+	for _, word := range splitWords(s) {
+		if strings.EqualFold(word, "and") {
+			names = append(names, strings.Join(name, " "))
+			name = nil
+			continue
+		}
+		name = append(name, word)
+	}
+	if len(name) > 0 {
+		names = append(names, strings.Join(name, " "))
+	}
+	return names
+}
+
+// splitWords splits s on whitespace outside braces.
+func splitWords(s string) []string {
+	var words []string
+	for _, word := range splitTopLevel(s, " ", "\t", "\n", "\r", "~") {
+		if word = strings.TrimSpace(word); word != "" {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// splitTopLevel splits s on any of seps that is not enclosed in braces.
+func splitTopLevel(s string, seps ...string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+			continue
+		case '}':
+			depth--
+			continue
+		}
+		if depth != 0 {
+			continue
+		}
+		for _, sep := range seps {
+			if sep != "" && strings.HasPrefix(s[i:], sep) {
+				parts = append(parts, s[start:i])
+				start = i + len(sep)
+				i = start - 1
+				break
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// nonEmpty returns the non-empty strings in ss.
+func nonEmpty(ss ...string) []string {
+	var res []string
+	for _, s := range ss {
+		if s != "" {
+			res = append(res, s)
+		}
+	}
+	return res
+}
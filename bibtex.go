@@ -2,8 +2,11 @@ package bibtex
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -18,6 +21,8 @@ type BibString interface {
 type BibVar struct {
 	Key   string    // Variable key.
 	Value BibString // Variable actual value.
+
+	forward bool // Placeholder for a reference before the definition.
 }
 
 // RawString is the internal representation of the variable.
@@ -29,14 +34,104 @@ func (v *BibVar) String() string {
 	return v.Value.String()
 }
 
+// RefString returns the reference to the variable as written in BibTeX, i.e.
+// its key, whereas String returns its value.
+func (v *BibVar) RefString() string {
+	return v.Key
+}
+
+// GoString returns the variable as Go syntax (for %#v), including its value.
+func (v *BibVar) GoString() string {
+	return goString(v, map[*BibVar]bool{})
+}
+
+// goString returns s as Go syntax. A variable already in seen (i.e. defined
+// in terms of itself) is shown without its value.
+func goString(s BibString, seen map[*BibVar]bool) string {
+	switch s := s.(type) {
+	case nil:
+		return "nil"
+	case BibConst:
+		return "bibtex.BibConst(" + strconv.Quote(string(s)) + ")"
+	case *BibVar:
+		if s == nil {
+			return "(*bibtex.BibVar)(nil)"
+		}
+		if seen[s] {
+			return fmt.Sprintf("&bibtex.BibVar{Key:%q, ...}", s.Key)
+		}
+		seen[s] = true
+		defer delete(seen, s)
+		return fmt.Sprintf("&bibtex.BibVar{Key:%q, Value:%s}", s.Key, goString(s.Value, seen))
+	case *BibComposite:
+		if s == nil {
+			return "(*bibtex.BibComposite)(nil)"
+		}
+		parts := make([]string, len(s.parts))
+		for i, part := range s.parts {
+			parts[i] = goString(part, seen)
+		}
+		return "&bibtex.BibComposite{" + strings.Join(parts, ", ") + "}"
+	}
+	return fmt.Sprintf("%#v", s)
+}
+
 // BibConst is a string constant.
 type BibConst string
 
-// NewBibConst converts a constant string to BibConst.
+// NewBibConst converts a constant string to BibConst. The braces in c must
+// be balanced (as BibTeX counts them, i.e. including \{ and \}) for RawString
+// and the encoders to produce valid BibTeX; use NewBibConstEscaped otherwise.
 func NewBibConst(c string) BibConst {
 	return BibConst(c)
 }
 
+// NewBibConstEscaped converts a constant string to BibConst, replacing the
+// braces of c without a matching brace (optionally escaped with a backslash)
+// with \textbraceleft{} or \textbraceright{}, so that the constant is always
+// valid BibTeX. Balanced braces are kept.
+func NewBibConstEscaped(c string) BibConst {
+	s := []rune(c)
+	unmatched := make(map[int]bool)
+	var open []int
+	for i, r := range s {
+		switch r {
+		case '{':
+			open = append(open, i)
+		case '}':
+			if len(open) == 0 {
+				unmatched[i] = true
+			} else {
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	for _, i := range open {
+		unmatched[i] = true
+	}
+	if len(unmatched) == 0 {
+		return BibConst(c)
+	}
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		brace := i
+		if s[i] == '\\' && i+1 < len(s) {
+			brace = i + 1
+		}
+		switch {
+		case !unmatched[brace]:
+			buf.WriteRune(s[i])
+			continue
+		case s[brace] == '{':
+			buf.WriteString(`\textbraceleft{}`)
+		default:
+			buf.WriteString(`\textbraceright{}`)
+		}
+		i = brace
+	}
+	return BibConst(buf.String())
+}
+
 // RawString is the internal representation of the constant (i.e. the string).
 func (c BibConst) RawString() string {
 	return fmt.Sprintf("{%s}", string(c))
@@ -46,8 +141,44 @@ func (c BibConst) String() string {
 	return string(c)
 }
 
+// GoString returns the constant as Go syntax (for %#v).
+func (c BibConst) GoString() string {
+	return goString(c, nil)
+}
+
+// Trim returns the constant without leading and trailing ASCII whitespace, as
+// BibTeX does before passing values to styles. Braces enclosing the whole
+// constant (e.g. "{Title}", protecting its case) are also removed.
+func (c BibConst) Trim() BibConst {
+	s := strings.Trim(string(c), " \t\n\r\v\f")
+	for len(s) >= 2 && s[0] == '{' && matchingBrace(s) == len(s)-1 {
+		s = strings.Trim(s[1:len(s)-1], " \t\n\r\v\f")
+	}
+	return BibConst(s)
+}
+
+// matchingBrace returns the index of the brace closing the brace at the start
+// of s, or -1 if it is not closed.
+func matchingBrace(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
 // BibComposite is a composite string, may contain both variable and string.
-type BibComposite []BibString
+// Its elements are added with Append and read with Parts.
+type BibComposite struct {
+	parts []BibString
+}
 
 // NewBibComposite creates a new composite with one element.
 func NewBibComposite(s BibString) *BibComposite {
@@ -57,42 +188,102 @@ func NewBibComposite(s BibString) *BibComposite {
 
 // Append adds a BibString to the composite
 func (c *BibComposite) Append(s BibString) *BibComposite {
-	comp := append(*c, s)
-	return &comp
+	c.parts = append(c.parts, s)
+	return c
+}
+
+// Parts returns a copy of the elements of the composite, in order. Nested
+// composites are not expanded (see Flatten).
+func (c *BibComposite) Parts() []BibString {
+	return append([]BibString(nil), c.parts...)
+}
+
+// Flatten returns the composite with nested composites expanded in place and
+// adjacent constants merged into a single BibConst. If the result has only one
+// element (e.g. the composite consists only of constants), that element is
+// returned instead of a composite.
+func (c *BibComposite) Flatten() BibString {
+	var flat []BibString
+	var buf strings.Builder
+	pending := false
+	for _, s := range c.flatten() {
+		if bc, ok := s.(BibConst); ok {
+			buf.WriteString(string(bc))
+			pending = true
+			continue
+		}
+		if pending {
+			flat = append(flat, BibConst(buf.String()))
+			buf.Reset()
+			pending = false
+		}
+		flat = append(flat, s)
+	}
+	if pending {
+		flat = append(flat, BibConst(buf.String()))
+	}
+	switch len(flat) {
+	case 0:
+		return NewBibConst("")
+	case 1:
+		return flat[0]
+	}
+	return &BibComposite{parts: flat}
 }
 
 func (c *BibComposite) String() string {
 	var buf bytes.Buffer
-	for _, s := range *c {
+	for _, s := range c.parts {
 		buf.WriteString(s.String())
 	}
 	return buf.String()
 }
 
+// GoString returns the composite and its elements as Go syntax (for %#v).
+func (c *BibComposite) GoString() string {
+	return goString(c, map[*BibVar]bool{})
+}
+
+// flatten returns the elements of the composite with nested composites
+// replaced by their elements, recursively.
+func (c *BibComposite) flatten() []BibString {
+	var flat []BibString
+	for _, s := range c.parts {
+		if comp, ok := s.(*BibComposite); ok {
+			flat = append(flat, comp.flatten()...)
+		} else {
+			flat = append(flat, s)
+		}
+	}
+	return flat
+}
+
 // RawString returns a raw (bibtex) representation of the composite string.
+// Nested composites are flattened, so the parts are always separated by a
+// single " # ".
 func (c *BibComposite) RawString() string {
 	var buf bytes.Buffer
-	for i, comp := range *c {
+	for i, comp := range c.flatten() {
 		if i > 0 {
 			buf.WriteString(" # ")
 		}
-		switch comp := comp.(type) {
-		case *BibConst:
-			buf.WriteString(comp.RawString())
-		case *BibVar:
-			buf.WriteString(comp.RawString())
-		case *BibComposite:
-			buf.WriteString(comp.RawString())
-		}
+		buf.WriteString(comp.RawString())
 	}
 	return buf.String()
 }
 
 // BibEntry is a record of BibTeX record.
+//
+// The CiteName is kept (and written) with its original casing, but like
+// \cite in BibTeX, entries are matched case-insensitively by cite name, e.g.
+// in EntryByKey and when checking for duplicates.
 type BibEntry struct {
 	Type     string
 	CiteName string
 	Fields   map[string]BibString
+
+	order        []string // Field names in the order they were added.
+	typeSpelling string   // Type as written, e.g. Article.
 }
 
 // NewBibEntry creates a new BibTeX entry.
@@ -101,22 +292,315 @@ func NewBibEntry(entryType string, citeName string) *BibEntry {
 	cleanedType := strings.ToLower(spaceStripper.Replace(entryType))
 	cleanedName := spaceStripper.Replace(citeName)
 	return &BibEntry{
-		Type:     cleanedType,
-		CiteName: cleanedName,
-		Fields:   map[string]BibString{},
+		Type:         cleanedType,
+		CiteName:     cleanedName,
+		Fields:       map[string]BibString{},
+		typeSpelling: spaceStripper.Replace(entryType),
+	}
+}
+
+// TypeSpelling returns the type of the entry as it was written when the entry
+// was created (e.g. by parsing), such as Article or INPROCEEDINGS, while Type
+// is always lower case. If Type was changed since, Type is returned.
+func (entry *BibEntry) TypeSpelling() string {
+	if strings.EqualFold(entry.typeSpelling, entry.Type) {
+		return entry.typeSpelling
 	}
+	return entry.Type
 }
 
 // AddField adds a field (key-value) to a BibTeX entry.
+// A new field is placed after the existing fields, replacing the value of an
+// existing field keeps its position.
 func (entry *BibEntry) AddField(name string, value BibString) {
-	entry.Fields[strings.TrimSpace(name)] = value
+	name = strings.TrimSpace(name)
+	if _, exists := entry.Fields[name]; !exists {
+		entry.order = append(entry.order, name)
+	}
+	entry.Fields[name] = value
+}
+
+// SetField sets a field of the entry as AddField, and returns the previous
+// value of the field, or nil if the entry did not have the field.
+func (entry *BibEntry) SetField(name string, value BibString) BibString {
+	old := entry.Fields[strings.TrimSpace(name)]
+	entry.AddField(name, value)
+	return old
+}
+
+// CopyFieldsTo copies the named fields (or all fields if no names are given)
+// of the entry to dst, in that order. Fields the entry does not have or dst
+// already has are skipped.
+func (entry *BibEntry) CopyFieldsTo(dst *BibEntry, fields ...string) {
+	if len(fields) == 0 {
+		fields = entry.OrderedFieldNames()
+	}
+	for _, name := range fields {
+		val, ok := entry.Fields[name]
+		if !ok {
+			continue
+		}
+		if _, exists := dst.Fields[name]; !exists {
+			dst.AddField(name, val)
+		}
+	}
+}
+
+// FieldNames returns the names of the fields of the entry in sorted order.
+func (entry *BibEntry) FieldNames() []string {
+	names := make([]string, 0, len(entry.Fields))
+	for name := range entry.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OrderedFieldNames returns the names of the fields of the entry in the order
+// they were added (or set by SortFieldsCanonical). Fields set directly in the
+// Fields map follow in sorted order.
+func (entry *BibEntry) OrderedFieldNames() []string {
+	names := make([]string, 0, len(entry.Fields))
+	seen := make(map[string]bool, len(entry.Fields))
+	for _, name := range entry.order {
+		if _, ok := entry.Fields[name]; ok && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	if len(names) == len(entry.Fields) {
+		return names
+	}
+	var rest []string
+	for name := range entry.Fields {
+		if !seen[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	return append(names, rest...)
+}
+
+// String returns the entry in BibTeX format, with field values in their
+// internal representation as in BibTex.RawString.
+func (entry *BibEntry) String() string {
+	var bibtex strings.Builder
+	NewEncoder(nil).writeEntry(&bibtex, entry)
+	return bibtex.String()
+}
+
+// field returns the value of the field name, matched case-insensitively (as
+// BibTeX does) if there is no field with exactly that name.
+func (entry *BibEntry) field(name string) (BibString, bool) {
+	if val, ok := entry.Fields[name]; ok {
+		return val, true
+	}
+	for _, key := range entry.OrderedFieldNames() {
+		if strings.EqualFold(key, name) {
+			return entry.Fields[key], true
+		}
+	}
+	return nil, false
+}
+
+// RawField returns the field name (matched case-insensitively) in its internal
+// representation, e.g. {Title} or a variable name, and whether the entry has
+// the field.
+func (entry *BibEntry) RawField(name string) (string, bool) {
+	val, ok := entry.field(name)
+	if !ok || val == nil {
+		return "", ok
+	}
+	return val.RawString(), true
+}
+
+// DisplayField returns the field name (matched case-insensitively) as it is
+// displayed, with string variables resolved, and whether the entry has the
+// field.
+func (entry *BibEntry) DisplayField(name string) (string, bool) {
+	val, ok := entry.field(name)
+	if !ok {
+		return "", false
+	}
+	return inlineString(val), true
+}
+
+// GetYear returns the year field of the entry as an integer. It returns an
+// error wrapping ErrMissingField if there is no year field, or ErrInvalidField
+// if the year is not numeric.
+func (entry *BibEntry) GetYear() (int, error) {
+	val, ok := entry.Fields["year"]
+	if !ok {
+		return 0, fmt.Errorf("%w: year in %s", ErrMissingField, entry.CiteName)
+	}
+	year, err := strconv.Atoi(strings.TrimSpace(val.String()))
+	if err != nil {
+		return 0, fmt.Errorf("%w: year in %s: %v", ErrInvalidField, entry.CiteName, err)
+	}
+	return year, nil
+}
+
+// Pages returns the first and last page of the pages field of the entry, e.g.
+// 123 and 456 for "123--456" (or "123-456"). For a single page, start and end
+// are the same. It returns an error wrapping ErrMissingField if there is no
+// pages field, or ErrInvalidField if a page is not numeric, e.g. "xii".
+func (entry *BibEntry) Pages() (start, end int, err error) {
+	pages, ok := entry.DisplayField("pages")
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: pages in %s", ErrMissingField, entry.CiteName)
+	}
+	first, sep, last := splitPageRange(pages)
+	if sep == "" {
+		last = first
+	}
+	if start, err = strconv.Atoi(first); err == nil {
+		end, err = strconv.Atoi(last)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: pages in %s: %v", ErrInvalidField, entry.CiteName, err)
+	}
+	return start, end, nil
+}
+
+// AbsoluteURL returns the URL of the entry: its url field if present, or the
+// arXiv abstract page https://arxiv.org/abs/{eprint} if it has an eprint field
+// with eprinttype (or archiveprefix) arxiv. Otherwise it returns an error
+// wrapping ErrMissingField.
+func (entry *BibEntry) AbsoluteURL() (string, error) {
+	if u, ok := entry.field("url"); ok {
+		if u := strings.TrimSpace(inlineString(u)); u != "" {
+			return u, nil
+		}
+	}
+	if eprint, ok := entry.field("eprint"); ok {
+		eprintType, ok := entry.field("eprinttype")
+		if !ok {
+			eprintType, ok = entry.field("archiveprefix")
+		}
+		eprint := strings.TrimSpace(inlineString(eprint))
+		if ok && strings.EqualFold(strings.TrimSpace(inlineString(eprintType)), "arxiv") && eprint != "" {
+			return "https://arxiv.org/abs/" + eprint, nil
+		}
+	}
+	return "", fmt.Errorf("%w: url in %s", ErrMissingField, entry.CiteName)
+}
+
+// ApplyStringVars resolves the string variables in the field values of the
+// entry using the string variables of bib, and replaces each value with the
+// resulting BibConst. Afterwards the entry no longer depends on bib.
+func (entry *BibEntry) ApplyStringVars(bib *BibTex) error {
+	for name, val := range entry.Fields {
+		resolved, err := bib.resolveString(val)
+		if err != nil {
+			return fmt.Errorf("%s in %s: %w", name, entry.CiteName, err)
+		}
+		entry.Fields[name] = resolved
+	}
+	return nil
 }
 
 // BibTex is a list of BibTeX entries.
 type BibTex struct {
 	Preambles []BibString        // List of Preambles
+	Comments  []string           // Contents of @comment entries, verbatim.
 	Entries   []*BibEntry        // Items in a bibliography.
 	StringVar map[string]*BibVar // Map from string variable to string.
+
+	stringVarKeys []string     // Keys of StringVar in order of definition.
+	commentPos    []int        // Number of entries before each comment.
+	index         *keyIndex    // Index of the cite keys of Entries, nil if not built.
+	log           *slog.Logger // Logger set by SetLogger, nil for the default.
+}
+
+// citeKey returns the form of a cite name used to match entries, which is
+// case-insensitive.
+func citeKey(citeName string) string {
+	return strings.ToLower(citeName)
+}
+
+// keyIndex is the position of the first entry with each cite key in entries.
+type keyIndex struct {
+	entries []*BibEntry // Entries of the BibTex when the index was built.
+	pos     map[string]int
+}
+
+// validIndex returns the index of the cite keys of the entries, or nil if it
+// is not built or entries were added to or removed from Entries since.
+func (bib *BibTex) validIndex() *keyIndex {
+	idx := bib.index
+	if idx == nil || len(idx.entries) != len(bib.Entries) {
+		return nil
+	}
+	if len(bib.Entries) > 0 && &idx.entries[0] != &bib.Entries[0] {
+		return nil
+	}
+	return idx
+}
+
+// keyIndex returns the index of the cite keys of the entries, building it if
+// necessary.
+func (bib *BibTex) keyIndex() *keyIndex {
+	if idx := bib.validIndex(); idx != nil {
+		return idx
+	}
+	idx := &keyIndex{entries: bib.Entries, pos: make(map[string]int, len(bib.Entries))}
+	for i, entry := range bib.Entries {
+		if _, exists := idx.pos[citeKey(entry.CiteName)]; !exists {
+			idx.pos[citeKey(entry.CiteName)] = i
+		}
+	}
+	bib.index = idx
+	return idx
+}
+
+// EntryByKey returns the first entry whose cite name matches key
+// case-insensitively, or nil if there is none. Entries are looked up in an
+// index, which is rebuilt when entries are added to or removed from Entries
+// (or reordered, renamed or rekeyed by the methods of BibTex). After changing
+// the CiteName of an entry directly, Rekey should be used instead so that the
+// new name is found. As the index is built on demand, EntryByKey must not be
+// called concurrently with other methods.
+func (bib *BibTex) EntryByKey(key string) *BibEntry {
+	key = citeKey(key)
+	i, ok := bib.keyIndex().pos[key]
+	if ok && citeKey(bib.Entries[i].CiteName) != key { // Entries changed in place.
+		bib.index = nil
+		i, ok = bib.keyIndex().pos[key]
+	}
+	if !ok {
+		return nil
+	}
+	return bib.Entries[i]
+}
+
+// CountEntries returns the number of entries, i.e. len(bib.Entries). It is
+// not cached, so it is always up to date after changes to Entries.
+func (bib *BibTex) CountEntries() int {
+	return len(bib.Entries)
+}
+
+// Keys returns the cite names of all entries in their current order, e.g. for
+// a \nocite list.
+func (bib *BibTex) Keys() []string {
+	keys := make([]string, len(bib.Entries))
+	for i, entry := range bib.Entries {
+		keys[i] = entry.CiteName
+	}
+	return keys
+}
+
+// Types returns the distinct entry types (compared case-insensitively) in
+// order of first appearance.
+func (bib *BibTex) Types() []string {
+	var types []string
+	seen := make(map[string]bool)
+	for _, entry := range bib.Entries {
+		if t := strings.ToLower(entry.Type); !seen[t] {
+			seen[t] = true
+			types = append(types, entry.Type)
+		}
+	}
+	return types
 }
 
 // NewBibTex creates a new BibTex data structure.
@@ -133,88 +617,449 @@ func (bib *BibTex) AddPreamble(p BibString) {
 	bib.Preambles = append(bib.Preambles, p)
 }
 
-// AddEntry adds an entry to the BibTeX data structure.
+// AddComment adds the content of a @comment entry to a bibtex, after the
+// entries added so far. It is written back in that position.
+func (bib *BibTex) AddComment(c string) {
+	for len(bib.commentPos) < len(bib.Comments) { // Comments added directly.
+		bib.commentPos = append(bib.commentPos, 0)
+	}
+	bib.Comments = append(bib.Comments, c)
+	bib.commentPos = append(bib.commentPos, len(bib.Entries))
+}
+
+// commentPosition returns the number of entries before the comment i, or 0 if
+// the comment was not added with AddComment.
+func (bib *BibTex) commentPosition(i int) int {
+	if i < len(bib.commentPos) {
+		return bib.commentPos[i]
+	}
+	return 0
+}
+
+// AddEntry adds an entry to the BibTeX data structure, even if an entry with
+// the same cite name exists. Use AddEntryUnique unless duplicates are wanted.
 func (bib *BibTex) AddEntry(entry *BibEntry) {
+	idx := bib.validIndex()
 	bib.Entries = append(bib.Entries, entry)
+	if idx != nil { // Keep the index up to date.
+		if _, exists := idx.pos[citeKey(entry.CiteName)]; !exists {
+			idx.pos[citeKey(entry.CiteName)] = len(bib.Entries) - 1
+		}
+		idx.entries = bib.Entries
+	}
+}
+
+// AddEntryUnique adds an entry to the BibTeX data structure, or returns an
+// error wrapping ErrDuplicateCiteKey if an entry with the same cite name
+// (compared case-insensitively) exists. It is the preferred way to add entries.
+func (bib *BibTex) AddEntryUnique(entry *BibEntry) error {
+	if bib.EntryByKey(entry.CiteName) != nil {
+		return fmt.Errorf("%w: %s", ErrDuplicateCiteKey, entry.CiteName)
+	}
+	bib.AddEntry(entry)
+	return nil
+}
+
+// RemoveDuplicateEntries removes all but the first entry with each cite name
+// (compared case-insensitively), e.g. when a file was included twice, and
+// returns the cite names of the removed entries in order.
+func (bib *BibTex) RemoveDuplicateEntries() []string {
+	seen := make(map[string]bool)
+	var removed []string
+	entries := bib.Entries[:0]
+	for _, entry := range bib.Entries {
+		if seen[citeKey(entry.CiteName)] {
+			removed = append(removed, entry.CiteName)
+			continue
+		}
+		seen[citeKey(entry.CiteName)] = true
+		entries = append(entries, entry)
+	}
+	clear(bib.Entries[len(entries):])
+	bib.Entries = entries
+	return removed
 }
 
 // AddStringVar adds a new string var (if does not exist).
+// A redefined string var keeps the position of its first definition.
 func (bib *BibTex) AddStringVar(key string, val BibString) {
+	if _, exists := bib.StringVar[key]; !exists {
+		bib.stringVarKeys = append(bib.stringVarKeys, key)
+	}
 	bib.StringVar[key] = &BibVar{Key: key, Value: val}
 }
 
-// GetStringVar looks up a string by its key.
-func (bib *BibTex) GetStringVar(key string) *BibVar {
-	if bv, ok := bib.StringVar[key]; ok {
-		return bv
+// AddStringVarIfAbsent adds a new string var only if key is not defined yet,
+// and returns true if it was added.
+func (bib *BibTex) AddStringVarIfAbsent(key string, val BibString) bool {
+	if _, exists := bib.StringVar[key]; exists {
+		return false
 	}
-	log.Fatalf("%s: %s", ErrUnknownStringVar, key)
+	bib.AddStringVar(key, val)
+	return true
+}
+
+// MergeStringVars copies the string variables of other into bib, in order of
+// their definition in other. A variable defined in both with a different
+// value is a conflict: it is replaced if overwrite is true and kept otherwise.
+// The keys of the conflicts are returned in order.
+func (bib *BibTex) MergeStringVars(other *BibTex, overwrite bool) []string {
+	var conflicts []string
+	for _, key := range other.StringVarKeys() {
+		v := other.StringVar[key]
+		if existing, ok := bib.StringVar[key]; ok {
+			if existing.Equal(v) {
+				continue
+			}
+			conflicts = append(conflicts, key)
+			if !overwrite {
+				continue
+			}
+		}
+		bib.AddStringVar(key, v.Value)
+	}
+	return conflicts
+}
+
+// AddStringVarChecked adds a new string var, or returns an error wrapping
+// ErrStringVarRedefined (and leaves the variable unchanged) if key is defined
+// already.
+func (bib *BibTex) AddStringVarChecked(key string, val BibString) error {
+	if _, exists := bib.StringVar[key]; exists {
+		return fmt.Errorf("%w: %s", ErrStringVarRedefined, key)
+	}
+	bib.AddStringVar(key, val)
 	return nil
 }
 
-// String returns a BibTex data structure as a simplified BibTex string.
-func (bib *BibTex) String() string {
-	var bibtex bytes.Buffer
-	for _, entry := range bib.Entries {
-		bibtex.WriteString(fmt.Sprintf("@%s{%s,\n", entry.Type, entry.CiteName))
-		for key, val := range entry.Fields {
-			if i, err := strconv.Atoi(strings.TrimSpace(val.String())); err == nil {
-				bibtex.WriteString(fmt.Sprintf("  %s = %d,\n", key, i))
-			} else {
-				bibtex.WriteString(fmt.Sprintf("  %s = {%s},\n", key, strings.TrimSpace(val.String())))
+// StringVarKeys returns the keys of the string vars in the order they were
+// defined. Keys added to StringVar directly (not with AddStringVar) follow in
+// sorted order.
+func (bib *BibTex) StringVarKeys() []string {
+	keys := make([]string, 0, len(bib.StringVar))
+	seen := make(map[string]bool, len(bib.StringVar))
+	for _, key := range bib.stringVarKeys {
+		if _, ok := bib.StringVar[key]; ok && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	var rest []string
+	for key := range bib.StringVar {
+		if !seen[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	return append(keys, rest...)
+}
+
+// GetStringVar looks up a string by its key.
+// It returns an error if the key is undefined or if resolving the variable
+// would loop forever because its definition (indirectly) refers to itself.
+func (bib *BibTex) GetStringVar(key string) (*BibVar, error) {
+	bv, ok := bib.StringVar[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownStringVar, key)
+	}
+	if err := checkStringVarCycle(bv, map[*BibVar]bool{}); err != nil {
+		return nil, err
+	}
+	return bv, nil
+}
+
+// resolveString resolves s to a constant, looking up variables by key in the
+// string variables of bib.
+func (bib *BibTex) resolveString(s BibString) (BibConst, error) {
+	switch s := s.(type) {
+	case *BibVar:
+		bv, err := bib.GetStringVar(s.Key)
+		if err != nil {
+			return "", err
+		}
+		return bib.resolveString(bv.Value)
+	case *BibComposite:
+		var buf strings.Builder
+		for _, comp := range s.parts {
+			c, err := bib.resolveString(comp)
+			if err != nil {
+				return "", err
 			}
+			buf.WriteString(string(c))
 		}
-		bibtex.Truncate(bibtex.Len() - 2)
-		bibtex.WriteString(fmt.Sprintf("\n}\n"))
+		return BibConst(buf.String()), nil
+	case nil:
+		return "", nil
 	}
-	return bibtex.String()
+	return BibConst(s.String()), nil
+}
+
+// checkStringVarCycle walks the value of s and reports ErrStringVarCycle if a
+// variable is encountered again while it is still being resolved.
+func checkStringVarCycle(s BibString, seen map[*BibVar]bool) error {
+	switch s := s.(type) {
+	case *BibVar:
+		if seen[s] {
+			return fmt.Errorf("%w: %s", ErrStringVarCycle, s.Key)
+		}
+		seen[s] = true
+		defer delete(seen, s)
+		return checkStringVarCycle(s.Value, seen)
+	case *BibComposite:
+		for _, comp := range s.parts {
+			if err := checkStringVarCycle(comp, seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// entrySizeHint is the estimated size of a serialised entry, used to
+// preallocate output buffers.
+const entrySizeHint = 256
+
+// String returns a BibTex data structure as a simplified BibTex string, with
+// string variables resolved (including in preambles). The result does not
+// need any @string definitions and parses on its own (see
+// Encoder.SelfContained). Use an Encoder with ExpandStrings and OmitPreambles
+// set to leave out the preambles.
+func (bib *BibTex) String() string {
+	return NewEncoder(nil).SelfContained(true).encodeString(bib)
 }
 
 // RawString returns a BibTex datastructure in its internal represenation.
 func (bib *BibTex) RawString() string {
-	var bibtex bytes.Buffer
-	for k, strvar := range bib.StringVar {
-		bibtex.WriteString(fmt.Sprintf("@string{%s = {%s}}\n", k, strvar.String()))
+	return NewEncoder(nil).encodeString(bib)
+}
+
+// ExpandStrings resolves the string variables in all entries and preambles,
+// replacing each value with a BibConst (see ApplyStringVars).
+func (bib *BibTex) ExpandStrings() error {
+	for i, preamble := range bib.Preambles {
+		resolved, err := bib.resolveString(preamble)
+		if err != nil {
+			return fmt.Errorf("preamble: %w", err)
+		}
+		bib.Preambles[i] = resolved
+	}
+	for _, entry := range bib.Entries {
+		if err := entry.ApplyStringVars(bib); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResolveStringVars replaces the value of every string variable with a
+// BibConst, resolving variables that refer to other variables (e.g.
+// @string{b = a # " extra"}) after the variables they depend on. References
+// in entries and preambles keep their binding (see String variables in the
+// package documentation): a reference to the current definition or one made
+// before any definition sees its resolved value, and a reference to an
+// earlier definition of a redefined variable keeps (the resolved value of)
+// that definition. It returns the errors (joined) for variables that are
+// (indirectly) defined in terms of themselves (ErrStringVarCycle) or of
+// undefined variables (ErrUnknownStringVar), which are left as they are.
+func (bib *BibTex) ResolveStringVars() error {
+	const (
+		resolving = iota + 1
+		resolved
+		failed
+	)
+	state := make(map[string]int)
+	var errs []error
+	var resolve func(key string) bool
+	// deps resolves the variables s refers to by key, and returns false if
+	// any of them cannot be resolved.
+	var deps func(s BibString, seen map[*BibVar]bool) bool
+	deps = func(s BibString, seen map[*BibVar]bool) bool {
+		ok := true
+		walkStringVars(s, func(v *BibVar) {
+			switch {
+			case bib.refersByKey(v):
+				ok = resolve(v.Key) && ok
+			case seen[v]:
+				errs = append(errs, fmt.Errorf("%w: %s", ErrStringVarCycle, v.Key))
+				ok = false
+			default:
+				seen[v] = true
+				ok = deps(v.Value, seen) && ok
+				delete(seen, v)
+			}
+		})
+		return ok
+	}
+	// value returns the resolved value of s, whose dependencies are resolved.
+	var value func(s BibString) string
+	value = func(s BibString) string {
+		switch s := s.(type) {
+		case nil:
+			return ""
+		case *BibVar:
+			if bib.refersByKey(s) {
+				return inlineString(bib.StringVar[s.Key].Value)
+			}
+			return value(s.Value)
+		case *BibComposite:
+			var buf strings.Builder
+			for _, part := range s.parts {
+				buf.WriteString(value(part))
+			}
+			return buf.String()
+		}
+		return s.String()
+	}
+	resolve = func(key string) bool {
+		bv, ok := bib.StringVar[key]
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Errorf("%w: %s", ErrUnknownStringVar, key))
+			return false
+		case state[key] == resolving:
+			errs = append(errs, fmt.Errorf("%w: %s", ErrStringVarCycle, key))
+			return false
+		case state[key] != 0:
+			return state[key] == resolved
+		}
+		state[key] = resolving
+		if !deps(bv.Value, map[*BibVar]bool{}) {
+			state[key] = failed
+			return false
+		}
+		bv.Value = BibConst(value(bv.Value))
+		state[key] = resolved
+		return true
+	}
+	for _, key := range bib.StringVarKeys() {
+		resolve(key)
+	}
+	refer := func(v *BibVar) {
+		switch {
+		case v.forward: // Placeholder for a reference before the definition.
+			if bv, ok := bib.StringVar[v.Key]; ok && state[v.Key] == resolved {
+				v.Value = bv.Value
+			}
+		case !bib.refersByKey(v): // Earlier definition of a redefined variable.
+			if deps(v.Value, map[*BibVar]bool{v: true}) {
+				v.Value = BibConst(value(v.Value))
+			}
+		}
 	}
 	for _, preamble := range bib.Preambles {
-		bibtex.WriteString(fmt.Sprintf("@preamble{%s}\n", preamble.RawString()))
+		walkStringVars(preamble, refer)
 	}
 	for _, entry := range bib.Entries {
-		bibtex.WriteString(fmt.Sprintf("@%s{%s,\n", entry.Type, entry.CiteName))
-		for key, val := range entry.Fields {
-			if i, err := strconv.Atoi(strings.TrimSpace(val.String())); err == nil {
-				bibtex.WriteString(fmt.Sprintf("  %s = %d,\n", key, i))
-			} else {
-				bibtex.WriteString(fmt.Sprintf("  %s = %s,\n", key, val.RawString()))
-			}
+		for _, val := range entry.Fields {
+			walkStringVars(val, refer)
 		}
-		bibtex.Truncate(bibtex.Len() - 2)
-		bibtex.WriteString(fmt.Sprintf("\n}\n"))
 	}
-	return bibtex.String()
+	return errors.Join(errs...)
 }
 
-// PrettyString pretty prints a bibtex.
-func (bib *BibTex) PrettyString() string {
-	var bibtex bytes.Buffer
+// refersByKey returns true if v refers to the string variable of bib with its
+// key rather than being an earlier definition of a redefined variable, which
+// has a value of its own.
+func (bib *BibTex) refersByKey(v *BibVar) bool {
+	return v.forward || v.Value == nil || bib.StringVar[v.Key] == v
+}
+
+// walkStringVars calls fn with each variable in s, without following the
+// values of the variables.
+func walkStringVars(s BibString, fn func(*BibVar)) {
+	switch s := s.(type) {
+	case *BibVar:
+		fn(s)
+	case *BibComposite:
+		for _, part := range s.parts {
+			walkStringVars(part, fn)
+		}
+	}
+}
+
+// TransformFieldValues replaces the value of the field fieldName of every entry
+// that has it with the value returned by fn, and returns the number of entries
+// changed (i.e. whose new value has a different RawString). If fn returns nil,
+// the field is removed.
+func (bib *BibTex) TransformFieldValues(fieldName string, fn func(BibString) BibString) int {
+	changed := 0
 	for _, entry := range bib.Entries {
-		bibtex.WriteString(fmt.Sprintf("@%s{%s,\n", entry.Type, entry.CiteName))
-		keylen := 0
-		for key := range entry.Fields {
-			if len(key) > keylen {
-				keylen = len(key)
-			}
+		val, ok := entry.Fields[fieldName]
+		if !ok {
+			continue
 		}
-		for key, val := range entry.Fields {
-			if i, err := strconv.Atoi(strings.TrimSpace(val.String())); err == nil {
-				bibtex.WriteString(fmt.Sprintf("  %s%s = %d,\n", key, strings.Repeat(" ", keylen-len(key)), i))
-			} else if strings.ContainsAny(val.String(), "\"{}") { // Certain characters should be {} quoted.
-				bibtex.WriteString(fmt.Sprintf("  %s%s = {%s},\n", key, strings.Repeat(" ", keylen-len(key)), val.String()))
+		transformed := fn(val)
+		if transformed == nil {
+			delete(entry.Fields, fieldName)
+			changed++
+			continue
+		}
+		if val == nil || transformed.RawString() != val.RawString() {
+			changed++
+		}
+		entry.AddField(fieldName, transformed)
+	}
+	return changed
+}
+
+// ForEach calls fn for each entry in order, and stops at the first error,
+// which is returned. As with Walk, the entries are those of bib when ForEach
+// is called, so fn may add or remove entries.
+func (bib *BibTex) ForEach(fn func(*BibEntry) error) error {
+	for _, entry := range append([]*BibEntry(nil), bib.Entries...) {
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Walk calls visit for each field of each entry, in order. The value returned
+// by visit replaces the value of the field, and returning nil removes the
+// field. The entries and fields visited are those of bib when Walk is called,
+// so visit may add or remove fields and entries.
+func (bib *BibTex) Walk(visit func(entry *BibEntry, field string, value BibString) BibString) {
+	entries := append([]*BibEntry(nil), bib.Entries...)
+	for _, entry := range entries {
+		for _, name := range entry.OrderedFieldNames() {
+			val, ok := entry.Fields[name]
+			if !ok { // Removed by an earlier visit.
+				continue
+			}
+			if replacement := visit(entry, name, val); replacement == nil {
+				delete(entry.Fields, name)
 			} else {
-				bibtex.WriteString(fmt.Sprintf("  %s%s = \"%s\",\n", key, strings.Repeat(" ", keylen-len(key)), val.String()))
+				entry.Fields[name] = replacement
 			}
 		}
-		bibtex.WriteString("}\n")
 	}
-	return bibtex.String()
+}
+
+// ExtractAbstracts returns the abstracts of the entries by cite name, with
+// string variables resolved. Entries without an abstract field are skipped.
+func (bib *BibTex) ExtractAbstracts() map[string]string {
+	abstracts := make(map[string]string)
+	if bib == nil {
+		return abstracts
+	}
+	for _, entry := range bib.Entries {
+		if abstract, ok := entry.Fields["abstract"]; ok && abstract != nil {
+			abstracts[entry.CiteName] = abstract.String()
+		}
+	}
+	return abstracts
+}
+
+// WriteTo writes the BibTex to w in its internal representation (as returned
+// by RawString). It implements io.WriterTo.
+func (bib *BibTex) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, bib.RawString())
+	return int64(n), err
+}
+
+// PrettyString pretty prints a bibtex, with string variables resolved, aligned
+// field values in double quotes where possible and no preambles.
+func (bib *BibTex) PrettyString() string {
+	return NewEncoder(nil).ExpandStrings(true).OmitPreambles(true).AlignFields(true).
+		QuoteStyle(DoubleQuotes).TrailingComma(true).encodeString(bib)
 }
@@ -2,15 +2,16 @@
 package bibtex
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"strings"
 )
 
 type bibTag struct {
 	key string
 	val BibString
 }
-
-var bibs = NewBibTex()
 %}
 
 %union {
@@ -22,7 +23,8 @@ var bibs = NewBibTex()
 }
 
 %token COMMENT STRING PREAMBLE
-%token ATSIGN COLON EQUAL COMMA POUND LBRACE RBRACE DQUOTE LPAREN RPAREN
+%token ILLEGAL /* An invalid token. */
+%token ATSIGN EQUAL COMMA POUND LBRACE RBRACE DQUOTE LPAREN RPAREN
 %token <strval> BAREIDENT IDENT
 %type <bibentry> bibentry
 %type <bibtag> tag
@@ -35,52 +37,133 @@ top : bibtex { }
     ;
 
 bibtex : /* empty */          { }
-       | bibtex bibentry      { bibs.AddEntry($2) }
+       | bibtex bibentry      { addEntry(bibtexlex, $2) }
        | bibtex commententry  { }
        | bibtex stringentry   { }
        | bibtex preambleentry { }
+       | bibtex error         { }
        ;
 
 bibentry : ATSIGN BAREIDENT LBRACE BAREIDENT COMMA tags RBRACE { $$ = NewBibEntry($2, $4); for _, t := range $6 { $$.AddField(t.key, t.val) } }
          | ATSIGN BAREIDENT LPAREN BAREIDENT COMMA tags RPAREN { $$ = NewBibEntry($2, $4); for _, t := range $6 { $$.AddField(t.key, t.val) } }
          ;
 
-commententry : ATSIGN COMMENT LBRACE longstring RBRACE {}
-             | ATSIGN COMMENT LPAREN longstring RBRACE {}
+commententry : ATSIGN COMMENT IDENT { bibOf(bibtexlex).AddComment($3) }
              ;
 
-stringentry : ATSIGN STRING LBRACE BAREIDENT EQUAL longstring RBRACE { bibs.AddStringVar($4, $6) }
-            | ATSIGN STRING LPAREN BAREIDENT EQUAL longstring RBRACE { bibs.AddStringVar($4, $6) }
+stringentry : ATSIGN STRING LBRACE BAREIDENT EQUAL longstring RBRACE { defineStringVar(bibtexlex, $4, $6) }
+            | ATSIGN STRING LPAREN BAREIDENT EQUAL longstring RBRACE { defineStringVar(bibtexlex, $4, $6) }
             ;
 
-preambleentry : ATSIGN PREAMBLE LBRACE longstring RBRACE { bibs.AddPreamble($4) }
-              | ATSIGN PREAMBLE LPAREN longstring RPAREN { bibs.AddPreamble($4) }
+preambleentry : ATSIGN PREAMBLE LBRACE longstring RBRACE { bibOf(bibtexlex).AddPreamble($4) }
+              | ATSIGN PREAMBLE LPAREN longstring RPAREN { bibOf(bibtexlex).AddPreamble($4) }
               ;
 
 longstring :                  IDENT     { $$ = NewBibConst($1) }
-           |                  BAREIDENT { $$ = bibs.GetStringVar($1) }
-           | longstring POUND IDENT     { $$ = NewBibComposite($1); $$.(*BibComposite).Append(NewBibConst($3))}
-           | longstring POUND BAREIDENT { $$ = NewBibComposite($1); $$.(*BibComposite).Append(bibs.GetStringVar($3)) }
+           |                  BAREIDENT { $$ = lookupStringVar(bibtexlex, $1) }
+           | longstring POUND IDENT     { $$ = concatString($1, NewBibConst($3)) }
+           | longstring POUND BAREIDENT { $$ = concatString($1, lookupStringVar(bibtexlex, $3)) }
            ;
 
-tag : /* empty */                { }
+tag : /* empty */                { $$ = nil }
     | BAREIDENT EQUAL longstring { $$ = &bibTag{key: $1, val: $3} }
     ;
 
-tags : tag            { $$ = []*bibTag{$1} }
+tags : tag            { if $1 == nil { $$ = nil } else { $$ = []*bibTag{$1} } }
      | tags COMMA tag { if $3 == nil { $$ = $1 } else { $$ = append($1, $3) } }
      ;
 
 %%
 
+// bibOf returns the BibTex being built by the lexer.
+func bibOf(l bibtexLexer) *BibTex {
+	return l.(*Lexer).bib
+}
+
+// lookupStringVar resolves a string variable reference to its current
+// definition. A variable that is not defined yet is resolved to its last
+// definition at the end of parsing, so that it may be defined after it is used.
+func lookupStringVar(l bibtexLexer, key string) BibString {
+	if _, defined := bibOf(l).StringVar[key]; !defined {
+		return l.(*Lexer).forwardStringVar(key)
+	}
+	v, err := bibOf(l).GetStringVar(key)
+	if err != nil {
+		l.Error(err.Error())
+		return NewBibConst("")
+	}
+	return v
+}
+
+// addEntry adds entry to the result. An entry with the cite name of an earlier
+// entry, and fields with an empty constant value, are reported as warnings.
+func addEntry(l bibtexLexer, entry *BibEntry) {
+	lex := l.(*Lexer)
+	if lex.citeKeys[citeKey(entry.CiteName)] {
+		lex.warn(fmt.Errorf("%w: %s", ErrDuplicateCiteKey, entry.CiteName), lex.entryStart)
+	}
+	lex.citeKeys[citeKey(entry.CiteName)] = true
+	for _, name := range entry.OrderedFieldNames() {
+		if c, ok := entry.Fields[name].(BibConst); ok && strings.TrimSpace(string(c)) == "" {
+			lex.warn(fmt.Errorf("%w: %s in %s", ErrEmptyField, name, entry.CiteName), lex.entryStart)
+		}
+	}
+	bibOf(l).AddEntry(entry)
+}
+
+// defineStringVar defines the string variable key. As in BibTeX, a variable
+// may be redefined (the last definition wins), which is reported as a warning.
+func defineStringVar(l bibtexLexer, key string, val BibString) {
+	lex := l.(*Lexer)
+	if err := bibOf(l).AddStringVarChecked(key, val); err != nil {
+		lex.warn(err, lex.entryStart)
+		bibOf(l).AddStringVar(key, val)
+	}
+}
+
+// concatString appends s to the composite string c, converting c to a
+// composite string first if necessary.
+func concatString(c, s BibString) BibString {
+	if comp, ok := c.(*BibComposite); ok {
+		return comp.Append(s)
+	}
+	return NewBibComposite(c).Append(s)
+}
+
 // Parse is the entry point to the bibtex parser.
+// Errors are returned as ParseError, with the line and column of the error.
 func Parse(r io.Reader) (*BibTex, error) {
-	l := NewLexer(r)
+	return parse(NewLexer(r))
+}
+
+// parse runs the parser on l and returns the result or the first error.
+// If the only errors are references to undefined string variables, all of
+// them are returned.
+func parse(l *Lexer) (*BibTex, error) {
 	bibtexParse(l)
+	undefined := l.resolveStringVars()
 	select {
 	case err := <-l.Errors:
 		return nil, err
 	default:
-		return bibs, nil
 	}
+	if len(undefined) > 0 {
+		errs := make([]error, len(undefined))
+		for i, err := range undefined {
+			errs[i] = err
+		}
+		return nil, errors.Join(errs...)
+	}
+	return l.bib, nil
+}
+
+// ParseWithRecovery is like Parse but does not stop at the first error.
+// When an entry cannot be parsed, the error is recorded and parsing resumes
+// from the next @ sign. The returned BibTex contains all entries that were
+// parsed successfully.
+func ParseWithRecovery(r io.Reader) (*BibTex, []ParseError) {
+	l := NewLexer(r)
+	bibtexParse(l)
+	l.resolveStringVars()
+	return l.bib, l.errs
 }
@@ -5,7 +5,10 @@ import __yyfmt__ "fmt"
 
 //line bibtex.y:2
 import (
+	"errors"
+	"fmt"
 	"io"
+	"strings"
 )
 
 type bibTag struct {
@@ -13,9 +16,7 @@ type bibTag struct {
 	val BibString
 }
 
-var bibs = NewBibTex()
-
-//line bibtex.y:16
+//line bibtex.y:17
 type bibtexSymType struct {
 	yys      int
 	strval   string
@@ -28,8 +29,8 @@ type bibtexSymType struct {
 const COMMENT = 57346
 const STRING = 57347
 const PREAMBLE = 57348
-const ATSIGN = 57349
-const COLON = 57350
+const ILLEGAL = 57349
+const ATSIGN = 57350
 const EQUAL = 57351
 const COMMA = 57352
 const POUND = 57353
@@ -48,8 +49,8 @@ var bibtexToknames = [...]string{
 	"COMMENT",
 	"STRING",
 	"PREAMBLE",
+	"ILLEGAL",
 	"ATSIGN",
-	"COLON",
 	"EQUAL",
 	"COMMA",
 	"POUND",
@@ -67,18 +68,99 @@ const bibtexEofCode = 1
 const bibtexErrCode = 2
 const bibtexInitialStackSize = 16
 
-//line bibtex.y:74
+//line bibtex.y:76
+
+// bibOf returns the BibTex being built by the lexer.
+func bibOf(l bibtexLexer) *BibTex {
+	return l.(*Lexer).bib
+}
+
+// lookupStringVar resolves a string variable reference to its current
+// definition. A variable that is not defined yet is resolved to its last
+// definition at the end of parsing, so that it may be defined after it is used.
+func lookupStringVar(l bibtexLexer, key string) BibString {
+	if _, defined := bibOf(l).StringVar[key]; !defined {
+		return l.(*Lexer).forwardStringVar(key)
+	}
+	v, err := bibOf(l).GetStringVar(key)
+	if err != nil {
+		l.Error(err.Error())
+		return NewBibConst("")
+	}
+	return v
+}
+
+// addEntry adds entry to the result. An entry with the cite name of an earlier
+// entry, and fields with an empty constant value, are reported as warnings.
+func addEntry(l bibtexLexer, entry *BibEntry) {
+	lex := l.(*Lexer)
+	if lex.citeKeys[citeKey(entry.CiteName)] {
+		lex.warn(fmt.Errorf("%w: %s", ErrDuplicateCiteKey, entry.CiteName), lex.entryStart)
+	}
+	lex.citeKeys[citeKey(entry.CiteName)] = true
+	for _, name := range entry.OrderedFieldNames() {
+		if c, ok := entry.Fields[name].(BibConst); ok && strings.TrimSpace(string(c)) == "" {
+			lex.warn(fmt.Errorf("%w: %s in %s", ErrEmptyField, name, entry.CiteName), lex.entryStart)
+		}
+	}
+	bibOf(l).AddEntry(entry)
+}
+
+// defineStringVar defines the string variable key. As in BibTeX, a variable
+// may be redefined (the last definition wins), which is reported as a warning.
+func defineStringVar(l bibtexLexer, key string, val BibString) {
+	lex := l.(*Lexer)
+	if err := bibOf(l).AddStringVarChecked(key, val); err != nil {
+		lex.warn(err, lex.entryStart)
+		bibOf(l).AddStringVar(key, val)
+	}
+}
+
+// concatString appends s to the composite string c, converting c to a
+// composite string first if necessary.
+func concatString(c, s BibString) BibString {
+	if comp, ok := c.(*BibComposite); ok {
+		return comp.Append(s)
+	}
+	return NewBibComposite(c).Append(s)
+}
 
 // Parse is the entry point to the bibtex parser.
+// Errors are returned as ParseError, with the line and column of the error.
 func Parse(r io.Reader) (*BibTex, error) {
-	l := NewLexer(r)
+	return parse(NewLexer(r))
+}
+
+// parse runs the parser on l and returns the result or the first error.
+// If the only errors are references to undefined string variables, all of
+// them are returned.
+func parse(l *Lexer) (*BibTex, error) {
 	bibtexParse(l)
+	undefined := l.resolveStringVars()
 	select {
 	case err := <-l.Errors:
 		return nil, err
 	default:
-		return bibs, nil
 	}
+	if len(undefined) > 0 {
+		errs := make([]error, len(undefined))
+		for i, err := range undefined {
+			errs[i] = err
+		}
+		return nil, errors.Join(errs...)
+	}
+	return l.bib, nil
+}
+
+// ParseWithRecovery is like Parse but does not stop at the first error.
+// When an entry cannot be parsed, the error is recorded and parsing resumes
+// from the next @ sign. The returned BibTex contains all entries that were
+// parsed successfully.
+func ParseWithRecovery(r io.Reader) (*BibTex, []ParseError) {
+	l := NewLexer(r)
+	bibtexParse(l)
+	l.resolveStringVars()
+	return l.bib, l.errs
 }
 
 //line yacctab:1
@@ -86,6 +168,9 @@ var bibtexExca = [...]int{
 	-1, 1,
 	1, -1,
 	-2, 0,
+	-1, 2,
+	1, 1,
+	-2, 0,
 }
 
 const bibtexNprod = 23
@@ -94,60 +179,59 @@ const bibtexPrivate = 57344
 var bibtexTokenNames []string
 var bibtexStates []string
 
-const bibtexLast = 61
+const bibtexLast = 55
 
 var bibtexAct = [...]int{
 
-	22, 39, 40, 41, 9, 10, 11, 24, 23, 44,
-	43, 27, 48, 26, 21, 20, 25, 8, 50, 28,
-	29, 33, 33, 49, 18, 16, 38, 19, 17, 14,
-	31, 12, 15, 42, 13, 30, 45, 46, 33, 33,
-	52, 51, 48, 36, 33, 47, 37, 33, 35, 34,
-	54, 53, 33, 7, 32, 6, 5, 4, 2, 1,
-	3,
+	24, 15, 36, 35, 10, 11, 12, 26, 25, 42,
+	41, 37, 44, 23, 33, 22, 21, 9, 46, 34,
+	27, 20, 18, 16, 13, 19, 17, 14, 33, 33,
+	48, 39, 40, 38, 33, 44, 47, 33, 43, 32,
+	29, 28, 45, 31, 30, 7, 50, 49, 6, 5,
+	4, 8, 2, 1, 3,
 }
 var bibtexPact = [...]int{
 
-	-1000, -1000, 46, -1000, -1000, -1000, -1000, 0, 19, 17,
-	13, 12, -2, -3, -10, -10, -4, -6, -10, -10,
-	25, 20, 41, -1000, -1000, 36, 39, 34, 33, 10,
-	-14, -14, -1000, -8, -1000, -10, -10, -1000, -1000, 32,
-	-1000, 14, 2, -1000, -1000, 28, 27, -1000, -14, -10,
-	-1000, -1000, -1000, -1000, 11,
+	-1000, -1000, 43, -1000, -1000, -1000, -1000, -1000, 0, 12,
+	-17, 11, 10, 4, -1, -1000, -2, -4, -10, -10,
+	31, 30, 35, 34, 26, -1000, -1000, 3, -6, -6,
+	-10, -10, -1000, -8, -1000, 25, -1000, 33, 2, 23,
+	17, -1000, -1000, -1000, -6, -10, -1000, -1000, -1000, -1000,
+	18,
 }
 var bibtexPgo = [...]int{
 
-	0, 60, 2, 1, 0, 59, 58, 57, 56, 55,
+	0, 54, 2, 3, 0, 53, 52, 50, 49, 48,
 }
 var bibtexR1 = [...]int{
 
-	0, 5, 6, 6, 6, 6, 6, 1, 1, 7,
+	0, 5, 6, 6, 6, 6, 6, 6, 1, 1,
 	7, 8, 8, 9, 9, 4, 4, 4, 4, 2,
 	2, 3, 3,
 }
 var bibtexR2 = [...]int{
 
-	0, 1, 0, 2, 2, 2, 2, 7, 7, 5,
-	5, 7, 7, 5, 5, 1, 1, 3, 3, 0,
+	0, 1, 0, 2, 2, 2, 2, 2, 7, 7,
+	3, 7, 7, 5, 5, 1, 1, 3, 3, 0,
 	3, 1, 3,
 }
 var bibtexChk = [...]int{
 
-	-1000, -5, -6, -1, -7, -8, -9, 7, 17, 4,
-	5, 6, 12, 15, 12, 15, 12, 15, 12, 15,
-	17, 17, -4, 18, 17, -4, 17, 17, -4, -4,
-	10, 10, 13, 11, 13, 9, 9, 13, 16, -3,
-	-2, 17, -3, 18, 17, -4, -4, 13, 10, 9,
-	16, 13, 13, -2, -4,
+	-1000, -5, -6, -1, -7, -8, -9, 2, 8, 17,
+	4, 5, 6, 12, 15, 18, 12, 15, 12, 15,
+	17, 17, 17, 17, -4, 18, 17, -4, 10, 10,
+	9, 9, 13, 11, 16, -3, -2, 17, -3, -4,
+	-4, 18, 17, 13, 10, 9, 16, 13, 13, -2,
+	-4,
 }
 var bibtexDef = [...]int{
 
-	2, -2, 1, 3, 4, 5, 6, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 15, 16, 0, 0, 0, 0, 0,
-	19, 19, 9, 0, 10, 0, 0, 13, 14, 0,
-	21, 0, 0, 17, 18, 0, 0, 7, 19, 0,
-	8, 11, 12, 22, 20,
+	2, -2, -2, 3, 4, 5, 6, 7, 0, 0,
+	0, 0, 0, 0, 0, 10, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 15, 16, 0, 19, 19,
+	0, 0, 13, 0, 14, 0, 21, 0, 0, 0,
+	0, 17, 18, 8, 19, 0, 9, 11, 12, 22,
+	20,
 }
 var bibtexTok1 = [...]int{
 
@@ -501,47 +585,43 @@ bibtexdefault:
 
 	case 1:
 		bibtexDollar = bibtexS[bibtexpt-1 : bibtexpt+1]
-		//line bibtex.y:34
+		//line bibtex.y:36
 		{
 		}
 	case 2:
 		bibtexDollar = bibtexS[bibtexpt-0 : bibtexpt+1]
-		//line bibtex.y:37
+		//line bibtex.y:39
 		{
 		}
 	case 3:
 		bibtexDollar = bibtexS[bibtexpt-2 : bibtexpt+1]
-		//line bibtex.y:38
+		//line bibtex.y:40
 		{
-			bibs.AddEntry(bibtexDollar[2].bibentry)
+			addEntry(bibtexlex, bibtexDollar[2].bibentry)
 		}
 	case 4:
 		bibtexDollar = bibtexS[bibtexpt-2 : bibtexpt+1]
-		//line bibtex.y:39
+		//line bibtex.y:41
 		{
 		}
 	case 5:
 		bibtexDollar = bibtexS[bibtexpt-2 : bibtexpt+1]
-		//line bibtex.y:40
+		//line bibtex.y:42
 		{
 		}
 	case 6:
 		bibtexDollar = bibtexS[bibtexpt-2 : bibtexpt+1]
-		//line bibtex.y:41
+		//line bibtex.y:43
 		{
 		}
 	case 7:
-		bibtexDollar = bibtexS[bibtexpt-7 : bibtexpt+1]
+		bibtexDollar = bibtexS[bibtexpt-2 : bibtexpt+1]
 		//line bibtex.y:44
 		{
-			bibtexVAL.bibentry = NewBibEntry(bibtexDollar[2].strval, bibtexDollar[4].strval)
-			for _, t := range bibtexDollar[6].bibtags {
-				bibtexVAL.bibentry.AddField(t.key, t.val)
-			}
 		}
 	case 8:
 		bibtexDollar = bibtexS[bibtexpt-7 : bibtexpt+1]
-		//line bibtex.y:45
+		//line bibtex.y:47
 		{
 			bibtexVAL.bibentry = NewBibEntry(bibtexDollar[2].strval, bibtexDollar[4].strval)
 			for _, t := range bibtexDollar[6].bibtags {
@@ -549,85 +629,93 @@ bibtexdefault:
 			}
 		}
 	case 9:
-		bibtexDollar = bibtexS[bibtexpt-5 : bibtexpt+1]
+		bibtexDollar = bibtexS[bibtexpt-7 : bibtexpt+1]
 		//line bibtex.y:48
 		{
+			bibtexVAL.bibentry = NewBibEntry(bibtexDollar[2].strval, bibtexDollar[4].strval)
+			for _, t := range bibtexDollar[6].bibtags {
+				bibtexVAL.bibentry.AddField(t.key, t.val)
+			}
 		}
 	case 10:
-		bibtexDollar = bibtexS[bibtexpt-5 : bibtexpt+1]
-		//line bibtex.y:49
+		bibtexDollar = bibtexS[bibtexpt-3 : bibtexpt+1]
+		//line bibtex.y:51
 		{
+			bibOf(bibtexlex).AddComment(bibtexDollar[3].strval)
 		}
 	case 11:
 		bibtexDollar = bibtexS[bibtexpt-7 : bibtexpt+1]
-		//line bibtex.y:52
+		//line bibtex.y:54
 		{
-			bibs.AddStringVar(bibtexDollar[4].strval, bibtexDollar[6].strings)
+			defineStringVar(bibtexlex, bibtexDollar[4].strval, bibtexDollar[6].strings)
 		}
 	case 12:
 		bibtexDollar = bibtexS[bibtexpt-7 : bibtexpt+1]
-		//line bibtex.y:53
+		//line bibtex.y:55
 		{
-			bibs.AddStringVar(bibtexDollar[4].strval, bibtexDollar[6].strings)
+			defineStringVar(bibtexlex, bibtexDollar[4].strval, bibtexDollar[6].strings)
 		}
 	case 13:
 		bibtexDollar = bibtexS[bibtexpt-5 : bibtexpt+1]
-		//line bibtex.y:56
+		//line bibtex.y:58
 		{
-			bibs.AddPreamble(bibtexDollar[4].strings)
+			bibOf(bibtexlex).AddPreamble(bibtexDollar[4].strings)
 		}
 	case 14:
 		bibtexDollar = bibtexS[bibtexpt-5 : bibtexpt+1]
-		//line bibtex.y:57
+		//line bibtex.y:59
 		{
-			bibs.AddPreamble(bibtexDollar[4].strings)
+			bibOf(bibtexlex).AddPreamble(bibtexDollar[4].strings)
 		}
 	case 15:
 		bibtexDollar = bibtexS[bibtexpt-1 : bibtexpt+1]
-		//line bibtex.y:60
+		//line bibtex.y:62
 		{
 			bibtexVAL.strings = NewBibConst(bibtexDollar[1].strval)
 		}
 	case 16:
 		bibtexDollar = bibtexS[bibtexpt-1 : bibtexpt+1]
-		//line bibtex.y:61
+		//line bibtex.y:63
 		{
-			bibtexVAL.strings = bibs.GetStringVar(bibtexDollar[1].strval)
+			bibtexVAL.strings = lookupStringVar(bibtexlex, bibtexDollar[1].strval)
 		}
 	case 17:
 		bibtexDollar = bibtexS[bibtexpt-3 : bibtexpt+1]
-		//line bibtex.y:62
+		//line bibtex.y:64
 		{
-			bibtexVAL.strings = NewBibComposite(bibtexDollar[1].strings)
-			bibtexVAL.strings.(*BibComposite).Append(NewBibConst(bibtexDollar[3].strval))
+			bibtexVAL.strings = concatString(bibtexDollar[1].strings, NewBibConst(bibtexDollar[3].strval))
 		}
 	case 18:
 		bibtexDollar = bibtexS[bibtexpt-3 : bibtexpt+1]
-		//line bibtex.y:63
+		//line bibtex.y:65
 		{
-			bibtexVAL.strings = NewBibComposite(bibtexDollar[1].strings)
-			bibtexVAL.strings.(*BibComposite).Append(bibs.GetStringVar(bibtexDollar[3].strval))
+			bibtexVAL.strings = concatString(bibtexDollar[1].strings, lookupStringVar(bibtexlex, bibtexDollar[3].strval))
 		}
 	case 19:
 		bibtexDollar = bibtexS[bibtexpt-0 : bibtexpt+1]
-		//line bibtex.y:66
+		//line bibtex.y:68
 		{
+			bibtexVAL.bibtag = nil
 		}
 	case 20:
 		bibtexDollar = bibtexS[bibtexpt-3 : bibtexpt+1]
-		//line bibtex.y:67
+		//line bibtex.y:69
 		{
 			bibtexVAL.bibtag = &bibTag{key: bibtexDollar[1].strval, val: bibtexDollar[3].strings}
 		}
 	case 21:
 		bibtexDollar = bibtexS[bibtexpt-1 : bibtexpt+1]
-		//line bibtex.y:70
+		//line bibtex.y:72
 		{
-			bibtexVAL.bibtags = []*bibTag{bibtexDollar[1].bibtag}
+			if bibtexDollar[1].bibtag == nil {
+				bibtexVAL.bibtags = nil
+			} else {
+				bibtexVAL.bibtags = []*bibTag{bibtexDollar[1].bibtag}
+			}
 		}
 	case 22:
 		bibtexDollar = bibtexS[bibtexpt-3 : bibtexpt+1]
-		//line bibtex.y:71
+		//line bibtex.y:73
 		{
 			if bibtexDollar[3].bibtag == nil {
 				bibtexVAL.bibtags = bibtexDollar[1].bibtags
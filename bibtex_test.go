@@ -2,9 +2,21 @@ package bibtex
 
 import (
 	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"html/template"
+	"io"
 	"io/ioutil"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+
+	"golang.org/x/text/encoding/charmap"
 )
 
 // Tests basic usage of bibtex library.
@@ -46,7 +58,11 @@ func TestString(t *testing.T) {
 	bibtex := NewBibTex()
 	bibtex.AddStringVar("cat", &BibVar{Key: "cat", Value: NewBibConst("meowmeow")})
 	entry := NewBibEntry("article", "abcd")
-	entry.AddField("title", bibtex.GetStringVar("cat"))
+	cat, err := bibtex.GetStringVar("cat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.AddField("title", cat)
 	bibtex.AddEntry(entry)
 
 	expected := `@article{abcd,
@@ -84,3 +100,2915 @@ func TestParser(t *testing.T) {
 		}
 	}
 }
+
+// Tests that cyclic string variables are reported instead of overflowing.
+func TestStringVarCycle(t *testing.T) {
+	bibtex := NewBibTex()
+	a := &BibVar{Key: "a"}
+	b := &BibVar{Key: "b", Value: a}
+	a.Value = NewBibComposite(NewBibConst("x")).Append(b)
+	bibtex.StringVar["a"] = a
+	bibtex.StringVar["b"] = b
+	if _, err := bibtex.GetStringVar("a"); !errors.Is(err, ErrStringVarCycle) {
+		t.Errorf("Expected cycle error but got %v", err)
+	}
+	if _, err := bibtex.GetStringVar("c"); !errors.Is(err, ErrUnknownStringVar) {
+		t.Errorf("Expected unknown variable error but got %v", err)
+	}
+}
+
+// Tests splitting and normalising keywords.
+func TestKeywords(t *testing.T) {
+	entry := NewBibEntry("article", "abcd")
+	entry.AddField("keywords", NewBibConst("Go; parsing, ,bibtex"))
+	entry.AddField("keyword", NewBibConst("go, LaTeX"))
+	expected := []string{"Go", "parsing", "bibtex", "LaTeX"}
+	if kw := entry.Keywords(); !reflect.DeepEqual(kw, expected) {
+		t.Errorf("Expected keywords %v but got %v", expected, kw)
+	}
+	entry.SetKeywords(append(entry.Keywords(), "Bibtex", "yacc"))
+	if _, ok := entry.Fields["keyword"]; ok {
+		t.Error("Expected keyword field to be removed.")
+	}
+	if kw := entry.Fields["keywords"].String(); kw != "Go, parsing, bibtex, LaTeX, yacc" {
+		t.Errorf("Unexpected keywords field: %s", kw)
+	}
+}
+
+// Tests parsing names in the three BibTeX forms.
+func TestParseAuthors(t *testing.T) {
+	authors, err := ParseAuthors("Donald E. Knuth and van Beethoven, Ludwig and King, Jr., Martin Luther and Jean de La Fontaine and {Barnes and Noble}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Author{
+		{First: "Donald E.", Last: "Knuth"},
+		{First: "Ludwig", Von: "van", Last: "Beethoven"},
+		{First: "Martin Luther", Last: "King", Jr: "Jr."},
+		{First: "Jean", Von: "de", Last: "La Fontaine"},
+		{Last: "{Barnes and Noble}"},
+	}
+	if !reflect.DeepEqual(authors, expected) {
+		t.Errorf("Expected %v but got %v", expected, authors)
+	}
+}
+
+// Tests formatting author lists for display.
+func TestFormatAuthors(t *testing.T) {
+	authors, _ := ParseAuthors("Donald E. Knuth and Leslie Lamport and Jean-Paul Sartre")
+	tests := []struct {
+		authors  []Author
+		max      int
+		style    NameStyle
+		expected string
+	}{
+		{authors[:1], 3, FullName, "Donald E. Knuth"},
+		{authors[:2], 3, LastInitials, "Knuth, D. E. and Lamport, L."},
+		{authors, 3, FullName, "Donald E. Knuth, Leslie Lamport, and Jean-Paul Sartre"},
+		{authors, 2, FullName, "Donald E. Knuth et al."},
+		{authors[2:], 0, LastInitials, "Sartre, J.-P."},
+		{append(authors[:1:1], Author{Last: Others}), 0, FullName, "Donald E. Knuth et al."},
+	}
+	for _, test := range tests {
+		if s := FormatAuthors(test.authors, test.max, test.style); s != test.expected {
+			t.Errorf("Expected %q but got %q", test.expected, s)
+		}
+	}
+}
+
+// Tests filtering entries by year range.
+func TestFilterByYear(t *testing.T) {
+	bibtex := NewBibTex()
+	for name, year := range map[string]string{"a": "2014", "b": "2015", "c": "2020", "d": "2021", "e": "n.d."} {
+		entry := NewBibEntry("article", name)
+		entry.AddField("year", NewBibConst(year))
+		bibtex.AddEntry(entry)
+	}
+	bibtex.AddEntry(NewBibEntry("misc", "f"))
+
+	errs := make(chan error, len(bibtex.Entries))
+	filtered := bibtex.FilterByYearErrors(2015, 2020, errs)
+	close(errs)
+	if len(filtered.Entries) != 2 {
+		t.Errorf("Expected 2 entries but got %d", len(filtered.Entries))
+	}
+	for _, entry := range filtered.Entries {
+		if entry.CiteName != "b" && entry.CiteName != "c" {
+			t.Errorf("Unexpected entry %s", entry.CiteName)
+		}
+	}
+	var missing, invalid int
+	for err := range errs {
+		switch {
+		case errors.Is(err, ErrMissingField):
+			missing++
+		case errors.Is(err, ErrInvalidField):
+			invalid++
+		}
+	}
+	if missing != 1 || invalid != 1 {
+		t.Errorf("Expected 1 missing and 1 invalid year but got %d and %d", missing, invalid)
+	}
+}
+
+// Tests that field names are returned in sorted order.
+func TestFieldNames(t *testing.T) {
+	entry := NewBibEntry("article", "abcd")
+	entry.AddField("year", NewBibConst("2020"))
+	entry.AddField("author", NewBibConst("A. Author"))
+	entry.AddField("title", NewBibConst("Title"))
+	expected := []string{"author", "title", "year"}
+	if names := entry.FieldNames(); !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected %v but got %v", expected, names)
+	}
+}
+
+// Tests abbreviating journal names with a JabRef abbreviation list.
+func TestAbbreviateJournals(t *testing.T) {
+	list := `# JabRef list
+Journal of the ACM;J. ACM
+Communications of the ACM = Commun. ACM
+`
+	table, err := ReadJournalAbbreviations(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bibtex := NewBibTex()
+	for name, journal := range map[string]string{"a": "journal of the ACM", "b": "Communications of the ACM", "c": "Unknown Journal"} {
+		entry := NewBibEntry("article", name)
+		entry.AddField("journal", NewBibConst(journal))
+		bibtex.AddEntry(entry)
+	}
+	bibtex.AbbreviateJournals(table)
+	expected := map[string]string{"a": "J. ACM", "b": "Commun. ACM", "c": "Unknown Journal"}
+	for _, entry := range bibtex.Entries {
+		if journal := entry.Fields["journal"].String(); journal != expected[entry.CiteName] {
+			t.Errorf("Expected journal %q but got %q", expected[entry.CiteName], journal)
+		}
+	}
+}
+
+// Tests that flattening a composite merges adjacent constants.
+func TestCompositeFlatten(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{v = "var"}
+@misc{flat, a = "foo" # " " # "bar", b = "foo" # " " # v # "bar" # "baz"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry *BibEntry
+	for _, e := range bib.Entries {
+		if e.CiteName == "flat" {
+			entry = e
+		}
+	}
+	if entry == nil {
+		t.Fatal("Entry not found.")
+	}
+	a := entry.Fields["a"].(*BibComposite)
+	if len(a.Parts()) != 3 {
+		t.Errorf("Expected 3 parts but got %d", len(a.Parts()))
+	}
+	if flat, ok := a.Flatten().(BibConst); !ok || flat != "foo bar" {
+		t.Errorf("Expected constant %q but got %#v", "foo bar", a.Flatten())
+	}
+	b, ok := entry.Fields["b"].(*BibComposite).Flatten().(*BibComposite)
+	if !ok || len(b.Parts()) != 3 {
+		t.Fatalf("Expected composite with 3 parts but got %#v", b)
+	}
+	if b.String() != "foo varbarbaz" {
+		t.Errorf("Expected %q but got %q", "foo varbarbaz", b.String())
+	}
+}
+
+// Tests removing empty fields.
+func TestRemoveEmptyFields(t *testing.T) {
+	bibtex := NewBibTex()
+	bibtex.AddStringVar("empty", NewBibConst(" "))
+	empty, _ := bibtex.GetStringVar("empty")
+	entry := NewBibEntry("article", "abcd")
+	entry.AddField("title", NewBibConst("Title"))
+	entry.AddField("pages", NewBibConst(""))
+	entry.AddField("note", NewBibConst(" \t"))
+	entry.AddField("month", empty)
+	bibtex.AddEntry(entry)
+	if n := bibtex.RemoveEmptyFields(); n != 3 {
+		t.Errorf("Expected 3 fields removed but got %d", n)
+	}
+	if names := entry.FieldNames(); !reflect.DeepEqual(names, []string{"title"}) {
+		t.Errorf("Unexpected fields left: %v", names)
+	}
+}
+
+// Tests converting EndNote tagged records to BibTeX entries.
+func TestParseEndNote(t *testing.T) {
+	enw := `%0 Journal Article
+%A Knuth, Donald E.
+%A Lamport, Leslie
+%T The Art of
+  Computer Programming
+%J Journal of the ACM
+%D 1984
+%P 1-10
+
+%0 Conference Paper
+%A Knuth, Donald E.
+%T Another Paper
+%B Proceedings
+%D 1984
+
+%0 Unknown Type
+%F mykey
+%T Untitled
+`
+	bib, err := ParseEndNote(strings.NewReader(enw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bib.Entries) != 3 {
+		t.Fatalf("Expected 3 entries but got %d", len(bib.Entries))
+	}
+	expected := []struct{ typ, key string }{{"article", "Knuth1984"}, {"inproceedings", "Knuth1984a"}, {"misc", "mykey"}}
+	for i, e := range expected {
+		if bib.Entries[i].Type != e.typ || bib.Entries[i].CiteName != e.key {
+			t.Errorf("Expected @%s{%s but got @%s{%s", e.typ, e.key, bib.Entries[i].Type, bib.Entries[i].CiteName)
+		}
+	}
+	fields := bib.Entries[0].Fields
+	if author := fields["author"].String(); author != "Knuth, Donald E. and Lamport, Leslie" {
+		t.Errorf("Unexpected author: %q", author)
+	}
+	if title := fields["title"].String(); title != "The Art of Computer Programming" {
+		t.Errorf("Unexpected title: %q", title)
+	}
+	if _, ok := bib.Entries[1].Fields["booktitle"]; !ok {
+		t.Error("Expected booktitle field.")
+	}
+}
+
+// Tests normalising entry type aliases.
+func TestNormalizeTypes(t *testing.T) {
+	bibtex := NewBibTex()
+	for i, typ := range []string{"Conference", "www", "article", "foo", "foo"} {
+		bibtex.AddEntry(NewBibEntry(typ, fmt.Sprint(i)))
+	}
+	bibtex.NormalizeTypes(nil)
+	expected := []string{"inproceedings", "online", "article", "foo", "foo"}
+	for i, entry := range bibtex.Entries {
+		if entry.Type != expected[i] {
+			t.Errorf("Expected type %s but got %s", expected[i], entry.Type)
+		}
+	}
+	if unknown := bibtex.UnknownTypes(); !reflect.DeepEqual(unknown, []string{"foo"}) {
+		t.Errorf("Expected unknown types [foo] but got %v", unknown)
+	}
+}
+
+// Tests that parsing resumes after malformed entries.
+func TestParseWithRecovery(t *testing.T) {
+	input := `@article{good1, title = {One}}
+@article{bad1, title = {Unclosed}
+@article{good2, title = {Two}}
+@article{bad2, title = {Unclosed value
+@article{good3, title = {Three}}
+`
+	bib, errs := ParseWithRecovery(strings.NewReader(input))
+	if len(errs) != 2 {
+		t.Errorf("Expected 2 errors but got %d: %v", len(errs), errs)
+	}
+	for i, line := range []int{2, 4} {
+		if i < len(errs) && errs[i].Line != line {
+			t.Errorf("Expected error at line %d but got %v", line, errs[i])
+		}
+	}
+	var keys []string
+	for _, entry := range bib.Entries {
+		keys = append(keys, entry.CiteName)
+	}
+	if expected := []string{"good1", "good2", "good3"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected entries %v but got %v", expected, keys)
+	}
+	if _, err := Parse(strings.NewReader(input)); err == nil {
+		t.Error("Expected Parse to fail.")
+	}
+}
+
+// Tests parsing and merging multiple files.
+func TestParseFiles(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.bib")
+	second := filepath.Join(dir, "second.bib")
+	broken := filepath.Join(dir, "broken.bib")
+	files := map[string]string{
+		first:  "@string{acm = {ACM}}\n@article{a, publisher = acm}\n",
+		second: "@article{b, publisher = acm # { Press}}\n@article{a, title = {Duplicate}}\n",
+		broken: "@article{c, title = }\n",
+	}
+	for path, content := range files {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bib, warnings, err := ParseFiles(first, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bib.Entries) != 2 {
+		t.Errorf("Expected 2 entries but got %d", len(bib.Entries))
+	}
+	if len(warnings) != 1 || !errors.Is(warnings[0], ErrDuplicateCiteKey) {
+		t.Errorf("Expected a duplicate cite key warning but got %v", warnings)
+	}
+	if publisher := bib.Entries[1].Fields["publisher"].String(); publisher != "ACM Press" {
+		t.Errorf("Expected publisher %q but got %q", "ACM Press", publisher)
+	}
+	if _, _, err := ParseFiles(first, broken); err == nil || !strings.Contains(err.Error(), broken) {
+		t.Errorf("Expected error mentioning %s but got %v", broken, err)
+	}
+}
+
+// Tests resolving string variables in place.
+func TestApplyStringVars(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{acm = {ACM}}
+@string{press = acm # { Press}}
+@article{a, publisher = press # {, New York}, title = {Title}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := bib.Entries[0]
+	if err := entry.ApplyStringVars(bib); err != nil {
+		t.Fatal(err)
+	}
+	if publisher, ok := entry.Fields["publisher"].(BibConst); !ok || publisher != "ACM Press, New York" {
+		t.Errorf("Expected constant %q but got %#v", "ACM Press, New York", entry.Fields["publisher"])
+	}
+	other := NewBibEntry("article", "b")
+	other.AddField("publisher", &BibVar{Key: "undefined", Value: NewBibConst("")})
+	if err := other.ApplyStringVars(bib); !errors.Is(err, ErrUnknownStringVar) {
+		t.Errorf("Expected unknown string variable error but got %v", err)
+	}
+}
+
+// Tests adding entries concurrently (run with -race).
+func TestSafeBibTex(t *testing.T) {
+	safe := NewSafeBibTex(nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := fmt.Sprintf("k%d-%d", i, j)
+				safe.AddEntry(NewBibEntry("misc", key))
+				safe.AddStringVar(key, NewBibConst(key))
+				safe.AddPreamble(NewBibConst(key))
+			}
+		}(i)
+	}
+	wg.Wait()
+	bib := safe.BibTex()
+	if len(bib.Entries) != 1600 || len(bib.StringVar) != 1600 || len(bib.Preambles) != 1600 {
+		t.Errorf("Expected 1600 entries, variables and preambles but got %d, %d and %d",
+			len(bib.Entries), len(bib.StringVar), len(bib.Preambles))
+	}
+}
+
+// benchBibTex creates a BibTex with n entries for benchmarks.
+func benchBibTex(n int) *BibTex {
+	bib := NewBibTex()
+	bib.AddStringVar("acm", NewBibConst("ACM"))
+	acm, _ := bib.GetStringVar("acm")
+	for i := 0; i < n; i++ {
+		entry := NewBibEntry("inproceedings", fmt.Sprintf("key%d", i))
+		entry.AddField("author", NewBibConst("Donald E. Knuth and Leslie Lamport"))
+		entry.AddField("title", NewBibConst("A Rather Long Title of a {BibTeX} Entry"))
+		entry.AddField("booktitle", NewBibComposite(NewBibConst("Proceedings of the ")).Append(acm))
+		entry.AddField("pages", NewBibConst("123--456"))
+		entry.AddField("year", NewBibConst("2020"))
+		bib.AddEntry(entry)
+	}
+	return bib
+}
+
+func BenchmarkString(b *testing.B) {
+	bib := benchBibTex(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = bib.String()
+	}
+}
+
+func BenchmarkRawString(b *testing.B) {
+	bib := benchBibTex(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = bib.RawString()
+	}
+}
+
+// Tests replacing journal names with a resolver.
+func TestApplyJournalResolver(t *testing.T) {
+	bibtex := NewBibTex()
+	for name, journal := range map[string]string{"a": "J. ACM", "b": "j. acm", "c": "Other"} {
+		entry := NewBibEntry("article", name)
+		entry.AddField("journal", NewBibConst(journal))
+		bibtex.AddEntry(entry)
+	}
+	bibtex.AddEntry(NewBibEntry("book", "d"))
+	if n := bibtex.ApplyJournalResolver(MapJournalResolver{"J. ACM": "Journal of the ACM"}); n != 1 {
+		t.Errorf("Expected 1 journal replaced but got %d", n)
+	}
+	for _, entry := range bibtex.Entries {
+		if entry.CiteName == "a" && entry.Fields["journal"].String() != "Journal of the ACM" {
+			t.Errorf("Unexpected journal: %s", entry.Fields["journal"])
+		}
+	}
+}
+
+// Tests writing a BibTex through io.WriterTo.
+func TestWriteTo(t *testing.T) {
+	bibtex := NewBibTex()
+	entry := NewBibEntry("article", "abcd1234")
+	entry.AddField("title", NewBibConst("HelloWorld"))
+	bibtex.AddEntry(entry)
+
+	var w io.WriterTo = bibtex
+	var buf bytes.Buffer
+	n, err := w.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != bibtex.RawString() || n != int64(buf.Len()) {
+		t.Errorf("Unexpected output (%d bytes): %s", n, buf.String())
+	}
+}
+
+// Tests output of entries with zero and one field, which must parse again.
+func TestEntryFieldCount(t *testing.T) {
+	bibtex := NewBibTex()
+	bibtex.AddEntry(NewBibEntry("misc", "empty"))
+	entry := NewBibEntry("misc", "one")
+	entry.AddField("title", NewBibConst("One"))
+	bibtex.AddEntry(entry)
+
+	expected := `@misc{empty,
+}
+@misc{one,
+  title = {One}
+}
+`
+	for _, output := range []string{bibtex.String(), bibtex.RawString()} {
+		if output != expected {
+			t.Errorf("Expected\n%s\nbut got\n%s", expected, output)
+		}
+		parsed, err := Parse(strings.NewReader(output))
+		if err != nil {
+			t.Fatalf("Cannot parse output: %v", err)
+		}
+		if parsed.String() != expected {
+			t.Errorf("Output does not round-trip:\n%s", parsed.String())
+		}
+	}
+}
+
+// Tests scanning the token stream with positions.
+func TestScanner(t *testing.T) {
+	s := NewScanner(strings.NewReader("@article{key,\n  title = {A {Title}} # x,\n}"))
+	expected := []struct {
+		tok          Token
+		lit          string
+		line, column int
+	}{
+		{ATSIGN, "@", 1, 1},
+		{BAREIDENT, "article", 1, 2},
+		{LBRACE, "{", 1, 9},
+		{BAREIDENT, "key", 1, 10},
+		{COMMA, ",", 1, 13},
+		{BAREIDENT, "title", 2, 3},
+		{EQUAL, "=", 2, 9},
+		{IDENT, "A {Title}", 2, 11},
+		{POUND, "#", 2, 23},
+		{BAREIDENT, "x", 2, 25},
+		{COMMA, ",", 2, 26},
+		{RBRACE, "}", 3, 1},
+		{EOF, "", 3, 2},
+	}
+	for _, e := range expected {
+		tok, lit, pos := s.Scan()
+		if tok != e.tok || lit != e.lit || pos.Line() != e.line || pos.Column() != e.column {
+			t.Errorf("Expected %s %q at %d:%d but got %s %q at %s", e.tok, e.lit, e.line, e.column, tok, lit, pos)
+		}
+	}
+}
+
+// Tests comparing BibTex values regardless of order.
+func TestBibTexEqual(t *testing.T) {
+	a, err := Parse(strings.NewReader(`@string{acm = {ACM}}
+@preamble{"\foo"}
+@article{x, publisher = acm, year = 2020}
+@book{y, title = {Book}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBibTex()
+	b.AddEntry(NewBibEntry("book", "y"))
+	b.Entries[0].AddField("title", NewBibConst("Book"))
+	x := NewBibEntry("article", "x")
+	x.AddField("year", NewBibConst("2020"))
+	x.AddField("publisher", NewBibConst("ACM"))
+	b.AddEntry(x)
+	b.AddPreamble(NewBibConst(`\foo`))
+	b.AddStringVar("acm", NewBibConst("ACM"))
+	if !a.Equal(b) || !b.Equal(a) {
+		t.Error("Expected BibTex to be equal.")
+	}
+	x.AddField("year", NewBibConst("2021"))
+	if a.Equal(b) {
+		t.Error("Expected BibTex with different field values to differ.")
+	}
+	x.AddField("year", NewBibConst("2020"))
+	for _, bib := range []*BibTex{a, b} { // Variables without a value.
+		undefined := NewBibEntry("misc", "u")
+		undefined.AddField("month", &BibVar{Key: "jan"})
+		bib.AddEntry(undefined)
+		bib.AddPreamble(&BibVar{Key: "pre"})
+	}
+	if !a.Equal(b) {
+		t.Error("Expected BibTex with undefined variables to be equal.")
+	}
+}
+
+// Tests that parse errors report the line and column of the offending token.
+func TestParseErrorPosition(t *testing.T) {
+	tests := []struct {
+		input        string
+		line, column int
+	}{
+		{"@article{a,\n  title = {A},\n  year 2020\n}", 3, 8},
+		{"@article{a, title = {A}}\n@article{b, month = undefined}", 2, 21},
+		{"@article{a,\n  title = {Unclosed\n@article{b}", 2, 11},
+	}
+	for _, test := range tests {
+		_, err := Parse(strings.NewReader(test.input))
+		var perr ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("Expected ParseError but got %v", err)
+			continue
+		}
+		if perr.Line != test.line || perr.Column != test.column {
+			t.Errorf("Expected error at %d:%d but got %v", test.line, test.column, perr)
+		}
+	}
+}
+
+// Tests parsing Latin-1 encoded input.
+func TestParseEncoding(t *testing.T) {
+	input := []byte("@misc{a, title = {Caf\xe9}}")
+	bib, err := ParseWithOptions(bytes.NewReader(input), ParseOptions{Encoding: charmap.ISO8859_1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title := bib.Entries[0].Fields["title"].String(); title != "Café" {
+		t.Errorf("Expected title %q but got %q", "Café", title)
+	}
+	bom := append([]byte("\xef\xbb\xbf"), "@misc{a, title = {Café}}"...)
+	bib, err = ParseWithOptions(bytes.NewReader(bom), ParseOptions{Encoding: charmap.ISO8859_1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title := bib.Entries[0].Fields["title"].String(); title != "Café" {
+		t.Errorf("Expected BOM to override encoding but got title %q", title)
+	}
+}
+
+// Tests exporting entries to CSV.
+func TestToCSV(t *testing.T) {
+	bib := NewBibTex()
+	a := NewBibEntry("article", "a")
+	a.AddField("title", NewBibConst("Hello, \"World\""))
+	a.AddField("year", NewBibConst("2020"))
+	bib.AddEntry(a)
+	bib.AddEntry(NewBibEntry("misc", "b"))
+	out, err := bib.ToCSV([]string{"title", "year"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "type,key,title,year\narticle,a,\"Hello, \"\"World\"\"\",2020\nmisc,b,,\n"
+	if out != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, out)
+	}
+}
+
+// Tests that a leading UTF-8 byte order mark is ignored.
+func TestParseBOM(t *testing.T) {
+	input := "@article{a,\n  title = {Title}\n}\n"
+	withBOM, err := Parse(strings.NewReader("\xef\xbb\xbf" + input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	withoutBOM, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if withBOM.RawString() != withoutBOM.RawString() {
+		t.Errorf("Expected\n%s\nbut got\n%s", withoutBOM.RawString(), withBOM.RawString())
+	}
+}
+
+// Tests reading the year as an integer.
+func TestGetYear(t *testing.T) {
+	entry := NewBibEntry("article", "a")
+	if _, err := entry.GetYear(); !errors.Is(err, ErrMissingField) {
+		t.Errorf("Expected missing field error but got %v", err)
+	}
+	entry.AddField("year", NewBibConst("forthcoming"))
+	if _, err := entry.GetYear(); !errors.Is(err, ErrInvalidField) {
+		t.Errorf("Expected invalid field error but got %v", err)
+	}
+	entry.AddField("year", NewBibConst(" 2020 "))
+	if year, err := entry.GetYear(); err != nil || year != 2020 {
+		t.Errorf("Expected 2020 but got %d (%v)", year, err)
+	}
+}
+
+// Tests that entries with and without a trailing comma after the last field
+// parse the same, and that the encoder can write either form.
+func TestTrailingComma(t *testing.T) {
+	without := "@article{a,\n  title = {Title},\n  year = 2020\n}\n"
+	with := "@article{a,\n  title = {Title},\n  year = 2020,\n}\n"
+	for _, input := range []string{without, with} {
+		bib, err := Parse(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("Cannot parse %q: %v", input, err)
+		}
+		for _, trailing := range []bool{false, true} {
+			var buf bytes.Buffer
+			if err := NewEncoder(&buf).TrailingComma(trailing).Encode(bib); err != nil {
+				t.Fatal(err)
+			}
+			expected := without
+			if trailing {
+				expected = with
+			}
+			if buf.String() != expected {
+				t.Errorf("Expected\n%s\nbut got\n%s", expected, buf.String())
+			}
+		}
+	}
+}
+
+// Tests preambles composed of several parts in raw and expanded output.
+func TestPreamble(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{cmd = {\providecommand}}
+@preamble{cmd # {{\noopsort}[1]{}} # " "}
+@misc{a, title = {A}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := bib.RawString()
+	if !strings.Contains(raw, "@preamble{cmd # {{\\noopsort}[1]{}} # { }}\n") {
+		t.Errorf("Unexpected raw preamble in\n%s", raw)
+	}
+	expected := "@preamble{{\\providecommand{\\noopsort}[1]{}}}\n@misc{a,\n  title = {A}\n}\n"
+	if bib.String() != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, bib.String())
+	}
+	if _, err := Parse(strings.NewReader(bib.String())); err != nil {
+		t.Errorf("Cannot parse expanded output: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).ExpandStrings(true).OmitPreambles(true).Encode(bib); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "@preamble") {
+		t.Errorf("Expected preambles to be omitted:\n%s", buf.String())
+	}
+	if err := bib.ExpandStrings(); err != nil {
+		t.Fatal(err)
+	}
+	if preamble, ok := bib.Preambles[0].(BibConst); !ok || preamble != "\\providecommand{\\noopsort}[1]{} " {
+		t.Errorf("Unexpected expanded preamble %#v", bib.Preambles[0])
+	}
+}
+
+// citeNames returns the cite names of the entries of bib in order.
+func citeNames(bib *BibTex) []string {
+	var names []string
+	for _, entry := range bib.Entries {
+		names = append(names, entry.CiteName)
+	}
+	return names
+}
+
+// Tests sorting entries by year.
+func TestSortByYear(t *testing.T) {
+	bib := NewBibTex()
+	for _, e := range [][2]string{{"a", "2020"}, {"b", ""}, {"c", "1999"}, {"d", "n.d."}, {"e", "2005"}} {
+		entry := NewBibEntry("misc", e[0])
+		if e[1] != "" {
+			entry.AddField("year", NewBibConst(e[1]))
+		}
+		bib.AddEntry(entry)
+	}
+	bib.SortByYear(true)
+	if names, expected := citeNames(bib), []string{"b", "d", "c", "e", "a"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected ascending order %v but got %v", expected, names)
+	}
+	bib.SortByYear(false)
+	if names, expected := citeNames(bib), []string{"a", "e", "c", "b", "d"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected descending order %v but got %v", expected, names)
+	}
+}
+
+// Tests printing a single entry.
+func TestEntryString(t *testing.T) {
+	bib := NewBibTex()
+	bib.AddStringVar("acm", NewBibConst("ACM"))
+	acm, _ := bib.GetStringVar("acm")
+	entry := NewBibEntry("article", "a")
+	entry.AddField("publisher", acm)
+	entry.AddField("title", NewBibConst("Title"))
+	bib.AddEntry(entry)
+	expected := "@article{a,\n  publisher = acm,\n  title = {Title}\n}\n"
+	if entry.String() != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, entry.String())
+	}
+	if !strings.HasSuffix(bib.RawString(), expected) {
+		t.Errorf("Expected entry output to match RawString:\n%s", bib.RawString())
+	}
+}
+
+// Tests that string variables are written in order of definition.
+func TestStringVarOrder(t *testing.T) {
+	input := `@string{zeta = {Z}}
+@string{alpha = zeta # {A}}
+@string{mid = {M}}
+@string{zeta = {Z2}}
+`
+	expected := "@string{zeta = {Z2}}\n@string{alpha = {ZA}}\n@string{mid = {M}}\n"
+	for i := 0; i < 10; i++ {
+		bib, err := Parse(strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if raw := bib.RawString(); raw != expected {
+			t.Fatalf("Expected\n%s\nbut got\n%s", expected, raw)
+		}
+	}
+}
+
+// Tests flattening deeply nested composites.
+func TestNestedComposite(t *testing.T) {
+	v := &BibVar{Key: "v", Value: NewBibConst("V")}
+	inner := NewBibComposite(NewBibConst("b")).Append(v)
+	nested := NewBibComposite(NewBibComposite(NewBibComposite(NewBibConst("a")).Append(inner))).
+		Append(NewBibComposite(NewBibComposite(NewBibComposite(NewBibConst("c"))))).
+		Append(NewBibConst("d"))
+	if raw := nested.RawString(); raw != "{a} # {b} # v # {c} # {d}" {
+		t.Errorf("Unexpected raw string %q", raw)
+	}
+	flat, ok := nested.Flatten().(*BibComposite)
+	if !ok || len(flat.Parts()) != 3 || flat.RawString() != "{ab} # v # {cd}" {
+		t.Errorf("Unexpected flattened composite %#v", nested.Flatten())
+	}
+	if nested.String() != "abVcd" || flat.String() != nested.String() {
+		t.Errorf("Unexpected string %q", flat.String())
+	}
+}
+
+// Tests rendering entries as HTML.
+func TestToHTML(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{jacm = {Journal of the ACM}}
+@article{knuth,
+  author = {Donald E. Knuth and Leslie Lamport},
+  title = {Sorting <and> {Searching}},
+  journal = jacm,
+  year = 1984,
+  doi = {10.1000/182}
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	html, err := bib.ToHTML("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<ul class="bibliography">
+  <li id="knuth">Knuth, D. E. and Lamport, L. (1984). Sorting &lt;and&gt; Searching. <em>Journal of the ACM</em>. <a href="https://doi.org/10.1000/182">doi:10.1000/182</a>
+  </li>
+</ul>
+`
+	if html != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, html)
+	}
+	if _, err := bib.ToHTML("{{.Bad"); err == nil {
+		t.Error("Expected template error.")
+	}
+}
+
+// Tests adding string variables only if absent.
+func TestAddStringVarIfAbsent(t *testing.T) {
+	bib := NewBibTex()
+	if !bib.AddStringVarIfAbsent("acm", NewBibConst("ACM")) {
+		t.Error("Expected new variable to be added.")
+	}
+	if bib.AddStringVarIfAbsent("acm", NewBibConst("Other")) {
+		t.Error("Expected existing variable not to be replaced.")
+	}
+	if v, _ := bib.GetStringVar("acm"); v.String() != "ACM" {
+		t.Errorf("Expected ACM but got %s", v)
+	}
+}
+
+// Tests that string variables may be used before their definition, and that
+// every undefined string variable is reported.
+func TestUndefinedStringVars(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{a, publisher = acm # { Press}}
+@string{acm = {ACM}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if publisher := bib.Entries[0].Fields["publisher"].String(); publisher != "ACM Press" {
+		t.Errorf("Expected forward reference to resolve but got %q", publisher)
+	}
+
+	input := `@article{a, month = jan}
+@article{b,
+  journal = jacm}`
+	_, err = Parse(strings.NewReader(input))
+	if err == nil {
+		t.Fatal("Expected undefined string variables to fail.")
+	}
+	for _, s := range []string{"jan", "jacm", "at 1:", "at 3:"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("Expected error to contain %q: %v", s, err)
+		}
+	}
+	if !errors.Is(err, ErrUnknownStringVar) {
+		t.Errorf("Unexpected error %v", err)
+	}
+	bib, err = ParseWithOptions(strings.NewReader(input), ParseOptions{AllowUndefinedStringVars: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if month := bib.Entries[0].Fields["month"].String(); month != "" {
+		t.Errorf("Expected undefined variable to be empty but got %q", month)
+	}
+}
+
+// Tests the resolution order of string variables defined in terms of each
+// other.
+func TestStringVarForwardReferences(t *testing.T) {
+	input := `@string{full = short # { Press}}
+@string{short = {ACM}}
+@misc{a, publisher = full, note = short}
+@string{short = {IEEE}}
+@misc{b, note = short}`
+	bib, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct{ entry, field, expected string }{
+		{"a", "publisher", "IEEE Press"}, // Forward reference: last definition.
+		{"a", "note", "ACM"},             // Definition in effect at this point.
+		{"b", "note", "IEEE"},
+	} {
+		if s := bib.EntryByKey(test.entry).Fields[test.field].String(); s != test.expected {
+			t.Errorf("Expected %s of %s to be %q but got %q", test.field, test.entry, test.expected, s)
+		}
+	}
+
+	_, err = Parse(strings.NewReader("@string{a = b}\n@string{b = a}\n@misc{c, note = a}"))
+	var perr ParseError
+	if !errors.Is(err, ErrStringVarCycle) || !errors.As(err, &perr) || perr.Line != 1 {
+		t.Errorf("Expected cycle error at line 1 but got %v", err)
+	}
+}
+
+// Tests listing the cite names and types of entries.
+func TestKeysAndTypes(t *testing.T) {
+	bib := NewBibTex()
+	for _, e := range [][2]string{{"article", "b"}, {"book", "a"}, {"Article", "c"}} {
+		bib.AddEntry(NewBibEntry(e[0], e[1]))
+	}
+	if n := bib.CountEntries(); n != 3 {
+		t.Errorf("Expected 3 entries but got %d", n)
+	}
+	if keys := bib.Keys(); !reflect.DeepEqual(keys, []string{"b", "a", "c"}) {
+		t.Errorf("Expected keys [b a c] but got %v", keys)
+	}
+	if types := bib.Types(); !reflect.DeepEqual(types, []string{"article", "book"}) {
+		t.Errorf("Expected types [article book] but got %v", types)
+	}
+}
+
+// Tests comparing strings by their resolved values.
+func TestBibStringEqual(t *testing.T) {
+	acm := &BibVar{Key: "acm", Value: NewBibConst("ACM")}
+	press := NewBibComposite(acm).Append(NewBibConst(" Press"))
+	if !BibStringEqual(press, NewBibConst("ACM Press")) {
+		t.Error("Expected composite to equal its resolved constant.")
+	}
+	if BibStringEqual(press, acm) {
+		t.Error("Expected different values not to be equal.")
+	}
+	if !BibStringEqual(nil, NewBibConst("")) || !BibStringEqual(&BibVar{Key: "x"}, nil) {
+		t.Error("Expected nil and undefined values to equal the empty string.")
+	}
+	if !acm.Equal(&BibVar{Key: "acm", Value: NewBibComposite(NewBibConst("AC")).Append(NewBibConst("M"))}) {
+		t.Error("Expected variables with the same key and value to be equal.")
+	}
+	if acm.Equal(&BibVar{Key: "ieee", Value: NewBibConst("ACM")}) {
+		t.Error("Expected variables with different keys not to be equal.")
+	}
+	if !press.Equal(NewBibComposite(NewBibConst("ACM Press"))) || NewBibConst("a").Equal(NewBibConst("A")) {
+		t.Error("Unexpected result comparing composites or constants.")
+	}
+}
+
+// Tests resolving the URL of an entry.
+func TestAbsoluteURL(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@misc{a, url = {https://example.org/a}, eprint = {2101.00001}, eprinttype = {arxiv}}
+@misc{b, eprint = {2101.00001}, eprinttype = {arXiv}}
+@misc{c, eprint = {hep-th/9901001}, archivePrefix = {arXiv}}
+@misc{d, eprint = {12345}, eprinttype = {pubmed}}
+@misc{e, title = {No URL}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expected := range []string{"https://example.org/a", "https://arxiv.org/abs/2101.00001", "https://arxiv.org/abs/hep-th/9901001", "", ""} {
+		u, err := bib.Entries[i].AbsoluteURL()
+		if expected == "" {
+			if !errors.Is(err, ErrMissingField) {
+				t.Errorf("Expected missing field error for %s but got %q, %v", bib.Entries[i].CiteName, u, err)
+			}
+			continue
+		}
+		if err != nil || u != expected {
+			t.Errorf("Expected URL %s but got %q, %v", expected, u, err)
+		}
+	}
+}
+
+// Tests cross-checking cite keys against a LaTeX source.
+func TestUnusedAndMissingKeys(t *testing.T) {
+	tex := `As shown by \citet[p.~3]{Knuth84}, and \cite{lamport94, knuth84}.
+% \cite{commented}
+Costs 100\% \parencite*{missing}\nocite{other}`
+	cited, err := CitedKeys(strings.NewReader(tex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"Knuth84", "lamport94", "knuth84", "missing", "other"}; !reflect.DeepEqual(cited, expected) {
+		t.Errorf("Expected cited keys %v but got %v", expected, cited)
+	}
+	bib := NewBibTex()
+	for _, key := range []string{"knuth84", "unused", "Lamport94", "other"} {
+		bib.AddEntry(NewBibEntry("misc", key))
+	}
+	if unused := bib.UnusedKeys(cited); !reflect.DeepEqual(unused, []string{"unused"}) {
+		t.Errorf("Expected unused keys [unused] but got %v", unused)
+	}
+	if missing := bib.MissingKeys(cited); !reflect.DeepEqual(missing, []string{"missing"}) {
+		t.Errorf("Expected missing keys [missing] but got %v", missing)
+	}
+	if unused := bib.UnusedKeys([]string{"*"}); unused != nil {
+		t.Errorf("Expected no unused keys with * but got %v", unused)
+	}
+}
+
+// Tests sorting entries by the family name of their first author.
+func TestSortByAuthor(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@misc{none, title = {No author}}
+@misc{lamport, author = {Leslie Lamport}}
+@misc{bad, author = {A, B, C, D}}
+@misc{knuth, author = {Knuth, Donald E. and Leslie Lamport}}
+@misc{erdos, author = {Paul Erd{\H o}s}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bib.SortByAuthor(true); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("Expected invalid name error but got %v", err)
+	}
+	if names := strings.Join(citeNames(bib), " "); names != "erdos knuth lamport none bad" {
+		t.Errorf("Unexpected ascending order %s", names)
+	}
+	bib.SortByAuthor(false)
+	if names := strings.Join(citeNames(bib), " "); names != "lamport knuth erdos none bad" {
+		t.Errorf("Unexpected descending order %s", names)
+	}
+}
+
+// Tests that @comment content is kept verbatim, even if it contains @ signs or
+// braces.
+func TestOpaqueComment(t *testing.T) {
+	comment := `jabref-meta: groupstree:
+@article{not an entry, {nested} braces}
+%`
+	bib, err := Parse(strings.NewReader("@Comment{" + comment + "}\n@misc{a, title = {T}}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(bib.Comments, []string{comment}) {
+		t.Errorf("Expected comment %q but got %q", comment, bib.Comments)
+	}
+	if len(bib.Entries) != 1 || bib.Entries[0].CiteName != "a" {
+		t.Errorf("Expected only entry a but got %v", citeNames(bib))
+	}
+	if raw := bib.RawString(); !strings.HasPrefix(raw, "@comment{"+comment+"}\n@misc{a,") {
+		t.Errorf("Expected comment to be written verbatim but got %s", raw)
+	}
+	if _, err := Parse(strings.NewReader("@comment{unbalanced {")); !errors.Is(err, ErrUnterminatedValue) {
+		t.Errorf("Expected unterminated comment error but got %v", err)
+	}
+
+	input := `@comment{header}
+@string{acm = "ACM"}
+@misc{a,
+  note = acm
+}
+@comment(between {a (b) c)
+@misc{b,
+  title = {B}
+}
+@comment{jabref-meta: databaseType:bibtex;}
+`
+	bib, err = Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(bib.Comments, []string{"header", "between {a (b) c", "jabref-meta: databaseType:bibtex;"}) {
+		t.Errorf("Unexpected comments %q", bib.Comments)
+	}
+	expected := `@comment{header}
+@string{acm = {ACM}}
+@misc{a,
+  note = acm
+}
+@comment(between {a (b) c)
+@misc{b,
+  title = {B}
+}
+@comment{jabref-meta: databaseType:bibtex;}
+`
+	if raw := bib.RawString(); raw != expected {
+		t.Errorf("Expected comments in place but got\n%s", raw)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(bib); err != nil {
+		t.Fatal(err)
+	}
+	var decoded BibTex
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if raw := decoded.RawString(); raw != expected {
+		t.Errorf("Expected comments in place after gob round trip but got\n%s", raw)
+	}
+}
+
+// Tests looking up entries by DOI.
+func TestFindByDOI(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@misc{a, doi = {https://doi.org/10.1000/ABC}}
+@misc{b, DOI = {10.1000/xyz}}
+@misc{c, title = {No DOI}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for doi, expected := range map[string]string{
+		"http://dx.doi.org/10.1000/xyz": "b",
+		"10.1000/xyz":                   "b",
+		"doi:10.1000/abc":               "a",
+		"10.1000/none":                  "",
+		"":                              "",
+	} {
+		var name string
+		if entry := bib.FindByDOI(doi); entry != nil {
+			name = entry.CiteName
+		}
+		if name != expected {
+			t.Errorf("Expected DOI %q to find %q but got %q", doi, expected, name)
+		}
+	}
+}
+
+// Tests replacing typographic punctuation with LaTeX input.
+func TestNormalizePunctuation(t *testing.T) {
+	bib, err := Parse(strings.NewReader("@string{acm = {ACM’s}}\n" +
+		"@misc{a, title = {“Go” – the ‘language’—done}, note = acm # {\u00a0Press’}, url = {https://example.org/a–b}}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bib.NormalizePunctuation()
+	entry := bib.Entries[0]
+	for name, expected := range map[string]string{
+		"title": "``Go'' -- the `language'---done",
+		"note":  "ACM’s~Press'",
+		"url":   "https://example.org/a–b",
+	} {
+		if s := entry.Fields[name].String(); s != expected {
+			t.Errorf("Expected %s %q but got %q", name, expected, s)
+		}
+	}
+	if raw := entry.Fields["note"].RawString(); !strings.HasPrefix(raw, "acm # ") {
+		t.Errorf("Expected variable to be kept but got %s", raw)
+	}
+}
+
+// Tests splitting titles into words for indexing.
+func TestWordsInTitle(t *testing.T) {
+	entry := NewBibEntry("article", "a")
+	if words := entry.WordsInTitle(); words != nil {
+		t.Errorf("Expected no words without title but got %v", words)
+	}
+	entry.AddField("title", NewBibConst(`{\"U}ber \emph{Go}: {GO} and {Go-lang}, 2nd ed.`))
+	expected := []string{"über", "go", "and", "lang", "2nd", "ed"}
+	if words := entry.WordsInTitle(); !reflect.DeepEqual(words, expected) {
+		t.Errorf("Expected words %v but got %v", expected, words)
+	}
+}
+
+// Tests reporting non-fatal problems as warnings, or as errors in strict mode.
+func TestParseWithWarnings(t *testing.T) {
+	input := `@string{acm = {ACM}}
+@misc{a, publisher = acm, note = { }}
+@string{acm = {ACM Press}}
+@misc{A, publisher = ieee}`
+	opts := ParseOptions{AllowUndefinedStringVars: true}
+	res, err := ParseWithWarnings(strings.NewReader(input), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Bib.Entries) != 2 {
+		t.Errorf("Expected 2 entries but got %v", citeNames(res.Bib))
+	}
+	expected := []struct {
+		err  error
+		line int
+	}{{ErrEmptyField, 2}, {ErrStringVarRedefined, 3}, {ErrDuplicateCiteKey, 4}, {ErrUnknownStringVar, 4}}
+	if len(res.Warnings) != len(expected) {
+		t.Fatalf("Expected %d warnings but got %v", len(expected), res.Warnings)
+	}
+	for i, w := range res.Warnings {
+		if !errors.Is(w, expected[i].err) || w.Line != expected[i].line {
+			t.Errorf("Expected warning %v at line %d but got %v", expected[i].err, expected[i].line, w)
+		}
+	}
+
+	opts.Strict = true
+	if _, err := ParseWithOptions(strings.NewReader(input), opts); !errors.Is(err, ErrDuplicateCiteKey) || !errors.Is(err, ErrEmptyField) {
+		t.Errorf("Expected warnings as errors in strict mode but got %v", err)
+	}
+	if _, err := ParseWithOptions(strings.NewReader(`@misc{a, title = {T}}`), opts); err != nil {
+		t.Errorf("Expected no error without warnings but got %v", err)
+	}
+}
+
+// Tests that escaped constants with unbalanced braces produce valid BibTeX.
+func TestNewBibConstEscaped(t *testing.T) {
+	tests := []struct{ in, expected string }{
+		{"Balanced {Go} and \\{x\\}", "Balanced {Go} and \\{x\\}"},
+		{"a } b { c", "a \\textbraceright{} b \\textbraceleft{} c"},
+		{"open \\{ only", "open \\textbraceleft{} only"},
+		{"}{", "\\textbraceright{}\\textbraceleft{}"},
+		{"{{x}", "\\textbraceleft{}{x}"},
+	}
+	for _, test := range tests {
+		c := NewBibConstEscaped(test.in)
+		if string(c) != test.expected {
+			t.Errorf("Expected %q to be escaped as %q but got %q", test.in, test.expected, c)
+		}
+		bib, err := Parse(strings.NewReader("@misc{a, title = " + c.RawString() + "}"))
+		if err != nil {
+			t.Errorf("Cannot parse escaped %q: %v", test.in, err)
+			continue
+		}
+		if title := bib.Entries[0].Fields["title"]; title != c {
+			t.Errorf("Expected %q to round-trip but got %q", c, title)
+		}
+	}
+	if s := LaTeXToUnicode(string(NewBibConstEscaped("a } b"))); s != "a } b" {
+		t.Errorf("Expected escaped brace to convert back but got %q", s)
+	}
+}
+
+// Tests formatting entries as a Markdown list in citation styles.
+func TestToMarkdown(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{knuth84,
+  author = {Knuth, Donald E.},
+  title = {Literate Programming},
+  journal = {The Computer Journal},
+  year = 1984, volume = 27, number = 2, pages = {97--111},
+  doi = {10.1093/comjnl/27.2.97}}
+@book{lamport94,
+  author = {Leslie Lamport and Donald E. Knuth and others},
+  title = {{\LaTeX}: A Document Preparation System?},
+  publisher = {Addison-Wesley}, year = 1994}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[MarkdownStyle]string{
+		APA: "- Knuth, D. E. (1984). Literate Programming. *The Computer Journal*, *27*(2), 97–111. <https://doi.org/10.1093/comjnl/27.2.97>\n" +
+			"- Lamport, L., Knuth, D. E., et al. (1994). *LaTeX: A Document Preparation System?* Addison-Wesley.\n",
+		MLA: "- Knuth, Donald E. \"Literate Programming.\" *The Computer Journal*, vol. 27, no. 2, 1984, pp. 97–111. [doi:10.1093/comjnl/27.2.97](https://doi.org/10.1093/comjnl/27.2.97)\n" +
+			"- Lamport, Leslie, et al. *LaTeX: A Document Preparation System?* Addison-Wesley, 1994.\n",
+		Chicago: "- Knuth, Donald E. 1984. \"Literate Programming.\" *The Computer Journal* 27 (2): 97–111. <https://doi.org/10.1093/comjnl/27.2.97>.\n" +
+			"- Lamport, Leslie, Donald E. Knuth, et al. 1994. *LaTeX: A Document Preparation System?* Addison-Wesley.\n",
+	}
+	for style, md := range expected {
+		if s := bib.ToMarkdown(style); s != md {
+			t.Errorf("Expected Markdown in style %d:\n%s\nbut got:\n%s", style, md, s)
+		}
+	}
+}
+
+// Tests merging the string variables of two bibliographies.
+func TestMergeStringVars(t *testing.T) {
+	newBib := func(vars ...string) *BibTex {
+		bib := NewBibTex()
+		for i := 0; i < len(vars); i += 2 {
+			bib.AddStringVar(vars[i], NewBibConst(vars[i+1]))
+		}
+		return bib
+	}
+	for _, overwrite := range []bool{false, true} {
+		bib := newBib("acm", "ACM", "ieee", "IEEE")
+		conflicts := bib.MergeStringVars(newBib("ieee", "IEEE", "jacm", "J. ACM", "acm", "ACM Press"), overwrite)
+		if !reflect.DeepEqual(conflicts, []string{"acm"}) {
+			t.Errorf("Expected conflict acm but got %v", conflicts)
+		}
+		expected := "ACM"
+		if overwrite {
+			expected = "ACM Press"
+		}
+		if acm := bib.StringVar["acm"].String(); acm != expected {
+			t.Errorf("Expected acm to be %q with overwrite %t but got %q", expected, overwrite, acm)
+		}
+		if keys := bib.StringVarKeys(); !reflect.DeepEqual(keys, []string{"acm", "ieee", "jacm"}) {
+			t.Errorf("Unexpected string variables %v", keys)
+		}
+	}
+}
+
+// Tests printing strings as Go syntax for debugging.
+func TestGoString(t *testing.T) {
+	acm := &BibVar{Key: "acm", Value: NewBibConst("ACM")}
+	press := NewBibComposite(acm).Append(NewBibConst(` "Press"`))
+	expected := `&bibtex.BibComposite{&bibtex.BibVar{Key:"acm", Value:bibtex.BibConst("ACM")}, bibtex.BibConst(" \"Press\"")}`
+	if s := fmt.Sprintf("%#v", press); s != expected {
+		t.Errorf("Expected %s but got %s", expected, s)
+	}
+	if acm.RefString() != "acm" || acm.String() != "ACM" {
+		t.Errorf("Unexpected reference %s or value %s", acm.RefString(), acm.String())
+	}
+	loop := &BibVar{Key: "loop"}
+	loop.Value = NewBibComposite(loop)
+	if s := fmt.Sprintf("%#v", loop); s != `&bibtex.BibVar{Key:"loop", Value:&bibtex.BibComposite{&bibtex.BibVar{Key:"loop", ...}}}` {
+		t.Errorf("Unexpected cyclic variable %s", s)
+	}
+}
+
+// Tests listing the required fields of standard entry types.
+func TestRequiredFields(t *testing.T) {
+	tests := []struct {
+		typ      string
+		strict   bool
+		expected []string
+	}{
+		{"Article", false, []string{"author", "title", "journal", "year"}},
+		{"article", true, []string{"author", "title", "journal", "year", "volume", "pages"}},
+		{"inbook", false, []string{"author/editor", "title", "chapter/pages", "publisher", "year"}},
+		{"inproceedings", true, []string{"author", "title", "booktitle", "year", "pages", "address"}},
+		{"misc", true, []string{}},
+		{"online", false, nil},
+	}
+	for _, test := range tests {
+		if fields := NewBibEntry(test.typ, "a").RequiredFields(test.strict); !reflect.DeepEqual(fields, test.expected) {
+			t.Errorf("Expected required fields %v of %s (strict %t) but got %v", test.expected, test.typ, test.strict, fields)
+		}
+	}
+}
+
+// Tests exporting an entry as a CSL-JSON item.
+func TestCSLItem(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{knuth84,
+  author = {Knuth, Donald E. and van der Berg, Jr., Jan and {The {\TeX} Group} and others},
+  title = {Literate {P}rogramming},
+  journal = {The Computer Journal},
+  year = 1984, month = {May}, volume = 27, number = 2, pages = {97--111},
+  doi = {10.1000/comjnl--27.2.97}, url = {http://www.cs.example.edu/~knuth/a--b.pdf}}
+@phdthesis{bad, year = {n.d.}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := bib.Entries[0].CSLItem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"DOI":"10.1000/comjnl--27.2.97","URL":"http://www.cs.example.edu/~knuth/a--b.pdf",` +
+		`"author":[{"family":"Knuth","given":"Donald E."},{"family":"Berg","given":"Jan","non-dropping-particle":"van der","suffix":"Jr."},{"family":"The TeX Group"}],` +
+		`"container-title":"The Computer Journal","id":"knuth84","issue":"2","issued":{"date-parts":[[1984,5]]},` +
+		`"page":"97-111","title":"Literate Programming","type":"article-journal","volume":"27"}`
+	if string(b) != expected {
+		t.Errorf("Expected CSL item\n%s\nbut got\n%s", expected, b)
+	}
+	if _, err := bib.Entries[1].CSLItem(); !errors.Is(err, ErrInvalidField) {
+		t.Errorf("Expected invalid year error but got %v", err)
+	}
+}
+
+// Tests linking DOIs and URLs in exports, and keeping them verbatim in BibTeX.
+func TestLinkifyIdentifiers(t *testing.T) {
+	input := `@article{a, title = {A}, doi = {https://doi.org/10.1002/(SICI)1097-4571(199806)49:8<693::AID-ASI4>3.0.CO;2-O}}
+@misc{b, title = {B}, url = {https://example.org/a_b?q=1}}
+@misc{c, title = {C}, url = {javascript:alert(1)}}
+@misc{d, title = {D}, url = {http://www.cs.example.edu/~knuth/a--b.pdf}}
+@misc{e, title = {E}, doi = {10.1000/a--b}}
+`
+	bib, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const doiURL = "https://doi.org/10.1002/%28SICI%291097-4571%28199806%2949:8%3C693::AID-ASI4%3E3.0.CO;2-O"
+	if u := NewEntryView(bib.Entries[0]).DOIURL(); u != doiURL {
+		t.Errorf("Unexpected DOI URL %s", u)
+	}
+	md := bib.ToMarkdownWithOptions(MLA, ExportOptions{LinkifyIdentifiers: true})
+	for _, s := range []string{"(" + doiURL + ")", "<https://example.org/a_b?q=1>", "javascript:alert(1)",
+		"<http://www.cs.example.edu/~knuth/a--b.pdf>", "(https://doi.org/10.1000/a--b)"} {
+		if !strings.Contains(md, s) {
+			t.Errorf("Expected Markdown to contain %s:\n%s", s, md)
+		}
+	}
+	if strings.Contains(md, "<javascript") {
+		t.Errorf("Expected only web URLs to be linked:\n%s", md)
+	}
+	md = bib.ToMarkdownWithOptions(MLA, ExportOptions{})
+	if strings.Contains(md, "https://doi.org") || strings.Contains(md, "<https") {
+		t.Errorf("Expected no links without LinkifyIdentifiers:\n%s", md)
+	}
+	html, err := bib.ToHTMLWithOptions("", ExportOptions{LinkifyIdentifiers: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html, `<a href="`+doiURL+`">`) ||
+		!strings.Contains(html, `<a href="https://example.org/a_b?q=1">`) || strings.Contains(html, `href="javascript`) {
+		t.Errorf("Unexpected links in HTML:\n%s", html)
+	}
+	if html, _ := bib.ToHTMLWithOptions("", ExportOptions{}); strings.Contains(html, "<a ") {
+		t.Errorf("Expected no links without LinkifyIdentifiers:\n%s", html)
+	}
+	if raw := bib.RawString(); !strings.Contains(raw, "{https://doi.org/10.1002/(SICI)1097-4571(199806)49:8<693::AID-ASI4>3.0.CO;2-O}") {
+		t.Errorf("Expected DOI to be kept verbatim in BibTeX:\n%s", raw)
+	}
+}
+
+// Tests writing entries as \bibitem lists.
+func TestWriteLatex(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{knuth84,
+  author = {Knuth, Donald E.}, title = {Literate Programming},
+  journal = {The Computer Journal}, year = 1984, volume = 27, number = 2, pages = {97--111}}
+@book{lamport94, author = {Leslie Lamport and Donald E. Knuth}, title = {{\LaTeX}},
+  publisher = {Addison-Wesley}, year = 1994}
+@inproceedings{knuth84b, author = {Donald E. Knuth}, title = {Why?}, booktitle = {Proc.}, year = 1984}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := bib.WriteLatex(&buf, Alpha); err != nil {
+		t.Fatal(err)
+	}
+	expected := `\bibitem[Knu84a]{knuth84}
+Donald E. Knuth.
+\newblock Literate Programming.
+\newblock {\em The Computer Journal}, 27(2):97--111, 1984.
+
+\bibitem[LK94]{lamport94}
+Leslie Lamport and Donald E. Knuth.
+\newblock {\em {\LaTeX}}.
+\newblock Addison-Wesley, 1994.
+
+\bibitem[Knu84b]{knuth84b}
+Donald E. Knuth.
+\newblock Why?
+\newblock In {\em Proc.}, 1984.
+
+`
+	if buf.String() != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, buf.String())
+	}
+	buf.Reset()
+	if err := bib.WriteLatex(&buf, Plain); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "\\bibitem{knuth84}\nDonald E. Knuth.\n") {
+		t.Errorf("Unexpected plain style\n%s", buf.String())
+	}
+}
+
+// Tests logging names that exports cannot parse to the logger of the BibTex.
+func TestSetLogger(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@misc{a, author = {A, B, C, D}, title = {T}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	bib.SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	if md := bib.ToMarkdown(APA); !strings.Contains(md, "A, B, C, D") {
+		t.Errorf("Expected names to be written as is but got %s", md)
+	}
+	if log := buf.String(); !strings.Contains(log, "level=WARN") || !strings.Contains(log, "op=ToMarkdown entry=a field=author") {
+		t.Errorf("Unexpected log %q", log)
+	}
+}
+
+// Tests the characters allowed in cite keys.
+func TestCiteKeyCharacters(t *testing.T) {
+	keys := []string{"Knuth:1984", "10.1000/xyz", "foo-bar_baz", "Müller2020", "_x+y?[1]", "a;b|c~d"}
+	for _, key := range keys {
+		bib, err := Parse(strings.NewReader("@misc{" + key + ", title = {T}}"))
+		if err != nil {
+			t.Errorf("Cannot parse cite key %s: %v", key, err)
+			continue
+		}
+		if name := bib.Entries[0].CiteName; name != key {
+			t.Errorf("Expected cite key %s but got %s", key, name)
+		}
+	}
+	for input, msg := range map[string]string{
+		"@misc{a{b}, title = {T}}":   `unexpected "{" after "a"`,
+		"@misc{a b, title = {T}}":    `unexpected "b" after "a"`,
+		"@misc{a(b), title = {T}}":   `unexpected "(" after "a"`,
+		"@misc{, title = {T}}":       `unexpected ","`,
+		"@misc{a#b, title = {T}}":    `unexpected "#" after "a"`,
+		"@misc{ok,\n title = {T} t}": "",
+	} {
+		_, err := Parse(strings.NewReader(input))
+		if msg == "" {
+			if errors.Is(err, ErrInvalidCiteKey) {
+				t.Errorf("Expected error in %q not to concern the cite key: %v", input, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrInvalidCiteKey) || !strings.Contains(err.Error(), msg) {
+			t.Errorf("Expected invalid cite key error %s for %q but got %v", msg, input, err)
+		}
+	}
+}
+
+// Tests listing the field changes between two versions of an entry.
+func TestFieldDiff(t *testing.T) {
+	old := NewBibEntry("article", "a")
+	old.AddField("title", NewBibConst("Title"))
+	old.AddField("pages", NewBibConst("1-2"))
+	old.AddField("note", NewBibConst("Note"))
+	old.AddField("Year", NewBibConst("1984"))
+	updated := NewBibEntry("article", "a")
+	updated.AddField("title", NewBibComposite(NewBibConst("Ti")).Append(NewBibConst("tle")))
+	updated.AddField("pages", NewBibConst("1--2"))
+	updated.AddField("year", NewBibConst("1984"))
+	updated.AddField("doi", NewBibConst("10.1000/1"))
+	d := old.FieldDiff(updated)
+	expected := FieldDiff{
+		Added:    map[string]BibString{"doi": NewBibConst("10.1000/1")},
+		Removed:  map[string]BibString{"note": NewBibConst("Note")},
+		Modified: map[string][2]BibString{"pages": {NewBibConst("1-2"), NewBibConst("1--2")}},
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Errorf("Expected %v but got %v", expected, d)
+	}
+	if d.Empty() || !old.FieldDiff(old).Empty() {
+		t.Error("Unexpected result of Empty.")
+	}
+}
+
+// Tests running a pipeline of transforms.
+func TestApply(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{acm = {ACM}}
+@misc{a, title = {  Two
+   lines  }, note = acm # {  Press}, pages = {1-2}, doi = {https://doi.org/10.1000/xyz}, url = {a  b}, empty = { }}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var steps []string
+	step := func(name string, err error) Transform {
+		return func(*BibTex) error {
+			steps = append(steps, name)
+			return err
+		}
+	}
+	if err := bib.Apply(NormalizeWhitespace, NormalizePages, NormalizeDOIs, RemoveEmptyFields, step("custom", nil)); err != nil {
+		t.Fatal(err)
+	}
+	entry := bib.Entries[0]
+	for name, expected := range map[string]string{
+		"title": "Two lines", "note": "ACM Press", "pages": "1--2", "doi": "10.1000/xyz", "url": "a  b",
+	} {
+		if s := entry.Fields[name].String(); s != expected {
+			t.Errorf("Expected %s %q but got %q", name, expected, s)
+		}
+	}
+	if _, ok := entry.Fields["empty"]; ok {
+		t.Error("Expected empty field to be removed.")
+	}
+	errStop := errors.New("stop")
+	if err := (Pipeline{step("first", nil), step("second", errStop), step("third", nil)}).Run(bib); err != errStop {
+		t.Errorf("Expected pipeline to return the error but got %v", err)
+	}
+	if expected := []string{"custom", "first", "second"}; !reflect.DeepEqual(steps, expected) {
+		t.Errorf("Expected steps %v but got %v", expected, steps)
+	}
+}
+
+// Tests merging biblatex @xdata entries into the entries referencing them.
+func TestResolveXData(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@xdata{acm, publisher = {ACM}, address = {New York}}
+@xdata{pldi, booktitle = {PLDI}, year = 2020, xdata = {acm}}
+@inproceedings{a, title = {A}, year = 2021, xdata = {pldi}}
+@misc{b, title = {B}, xdata = {missing, a, acm}}
+@xdata{loop, note = {N}, xdata = {loop}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bib.ResolveXData()
+	if !errors.Is(err, ErrUnknownCiteKey) || !errors.Is(err, ErrInvalidField) {
+		t.Errorf("Expected unknown and invalid xdata errors but got %v", err)
+	}
+	for _, s := range []string{"xdata missing", "a is not an @xdata entry", "loop refers back"} {
+		if err == nil || !strings.Contains(err.Error(), s) {
+			t.Errorf("Expected error to contain %q: %v", s, err)
+		}
+	}
+	a := bib.EntryByKey("a")
+	expected := map[string]string{"title": "A", "year": "2021", "booktitle": "PLDI", "publisher": "ACM", "address": "New York"}
+	if len(a.Fields) != len(expected) {
+		t.Errorf("Expected fields %v but got %v", expected, a.FieldNames())
+	}
+	for name, val := range expected {
+		if s := a.Fields[name].String(); s != val {
+			t.Errorf("Expected %s %q but got %q", name, val, s)
+		}
+	}
+	if publisher := bib.EntryByKey("b").Fields["publisher"]; publisher == nil || publisher.String() != "ACM" {
+		t.Errorf("Expected valid references to be resolved but got %v", publisher)
+	}
+}
+
+// Tests grouping entries with similar titles.
+func TestFindSimilar(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@misc{a, title = {Literate Programming}}
+@misc{b, title = {Sorting and Searching}}
+@misc{c, title = {{L}iterate programming.}}
+@misc{d, title = {Searching and Sorting}}
+@misc{e, title = {Literate Programing}}
+@misc{f,}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := func(groups [][]*BibEntry) string {
+		var s []string
+		for _, g := range groups {
+			var keys []string
+			for _, entry := range g {
+				keys = append(keys, entry.CiteName)
+			}
+			s = append(s, strings.Join(keys, ","))
+		}
+		return strings.Join(s, " ")
+	}
+	if groups := names(bib.FindSimilar(0.9)); groups != "a,c b,d" {
+		t.Errorf("Expected groups a,c b,d but got %s", groups)
+	}
+	if groups := names(bib.FindSimilarFunc(0.9, LevenshteinRatio)); groups != "a,c,e" {
+		t.Errorf("Expected group a,c,e but got %s", groups)
+	}
+	if r := LevenshteinRatio("kitten", "sitting"); r < 0.57 || r > 0.58 {
+		t.Errorf("Unexpected Levenshtein ratio %f", r)
+	}
+}
+
+// Tests parsing and normalising author lists with a custom separator.
+func TestNormalizeAuthorFields(t *testing.T) {
+	input := `@book{a, author = {Knuth, Donald;  Lamport, Leslie; {Tom; Jerry}}, editor = {Doe, John}}
+@book{b, author = {Donald Knuth; Leslie Lamport and Tom}}`
+	bib, err := ParseWithOptions(strings.NewReader(input), ParseOptions{AuthorSeparator: "; "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if author := bib.Entries[0].Fields["author"].String(); author != "Knuth, Donald and Lamport, Leslie and {Tom; Jerry}" {
+		t.Errorf("Unexpected author %q", author)
+	}
+	if editor := bib.Entries[0].Fields["editor"].String(); editor != "Doe, John" {
+		t.Errorf("Unexpected editor %q", editor)
+	}
+	if authors, err := ParseAuthors(bib.Entries[0].Fields["author"].String()); err != nil || len(authors) != 3 {
+		t.Errorf("Expected 3 authors but got %v (%v)", authors, err)
+	}
+
+	bib, err = Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bib.NormalizeAuthorFields(ParseOptions{}); n != 0 {
+		t.Errorf("Expected no fields changed with the default separator but got %d", n)
+	}
+	if n := bib.NormalizeAuthorFields(ParseOptions{AuthorSeparator: ";"}); n != 2 {
+		t.Errorf("Expected 2 fields changed but got %d", n)
+	}
+	if author := bib.Entries[1].Fields["author"].String(); author != "Donald Knuth and Leslie Lamport and Tom" {
+		t.Errorf("Unexpected author %q", author)
+	}
+}
+
+// Tests that the parts of a composite string are a copy.
+func TestBibCompositeParts(t *testing.T) {
+	comp := NewBibComposite(NewBibConst("a")).Append(&BibVar{Key: "b", Value: NewBibConst("b")})
+	parts := comp.Parts()
+	if len(parts) != 2 || parts[0] != NewBibConst("a") {
+		t.Fatalf("Unexpected parts %#v", parts)
+	}
+	parts[0] = NewBibConst("x")
+	if s := comp.String(); s != "ab" {
+		t.Errorf("Expected composite to be unchanged but got %q", s)
+	}
+}
+
+// Tests writing entry types as they were written in the input.
+func TestPreserveTypeCase(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@Article{a, title = {A}}
+@INPROCEEDINGS{b, title = {B}}
+@misc{c, title = {C}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if types := bib.Types(); strings.Join(types, ",") != "article,inproceedings,misc" {
+		t.Errorf("Expected lower-case types but got %v", types)
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).PreserveTypeCase(true).Encode(bib); err != nil {
+		t.Fatal(err)
+	}
+	for _, typ := range []string{"@Article{a,", "@INPROCEEDINGS{b,", "@misc{c,"} {
+		if !strings.Contains(buf.String(), typ) {
+			t.Errorf("Expected %s in output:\n%s", typ, buf.String())
+		}
+	}
+	reparsed, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := reparsed.Entries[1].TypeSpelling(); s != "INPROCEEDINGS" {
+		t.Errorf("Expected type spelling INPROCEEDINGS after round trip but got %s", s)
+	}
+	if s := bib.String(); !strings.Contains(s, "@article{a,") || !strings.Contains(s, "@inproceedings{b,") {
+		t.Errorf("Expected lower-case types by default but got:\n%s", s)
+	}
+	bib.Entries[0].Type = "book"
+	if s := bib.Entries[0].TypeSpelling(); s != "book" {
+		t.Errorf("Expected changed type book but got %s", s)
+	}
+}
+
+// Tests removing entries with duplicate cite names.
+func TestRemoveDuplicateEntries(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@misc{a, title = {First}}
+@misc{b,}
+@misc{A, title = {Second}}
+@misc{b,}
+@misc{c,}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed := bib.RemoveDuplicateEntries(); strings.Join(removed, ",") != "A,b" {
+		t.Errorf("Expected A,b removed but got %v", removed)
+	}
+	if names := strings.Join(citeNames(bib), ","); names != "a,b,c" {
+		t.Errorf("Expected entries a,b,c but got %s", names)
+	}
+	if title, _ := bib.Entries[0].DisplayField("title"); title != "First" {
+		t.Errorf("Expected the first entry to be kept but got title %q", title)
+	}
+	if removed := bib.RemoveDuplicateEntries(); removed != nil {
+		t.Errorf("Expected nothing removed but got %v", removed)
+	}
+}
+
+// Tests encoding a bibliography as JSON and decoding it.
+func TestJSON(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{zz = "ZZ"}
+@string{acm = "ACM"}
+@preamble{"\relax"}
+@article{knuth, title = {Literate "Programming"}, publisher = acm # " Press", year = 1984}
+@misc{b, note = zz}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(bib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"entries":[` +
+		`{"type":"article","key":"knuth","fields":{"title":"Literate \"Programming\"","publisher":"ACM Press","year":"1984"}},` +
+		`{"type":"misc","key":"b","fields":{"note":"ZZ"}}],` +
+		`"strings":{"zz":"ZZ","acm":"ACM"},"preambles":["\\relax"]}`
+	if string(data) != expected {
+		t.Errorf("Unexpected JSON\n%s\nexpected\n%s", data, expected)
+	}
+	var decoded BibTex
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := json.Marshal(&decoded); err != nil || string(again) != string(data) {
+		t.Errorf("Expected the same JSON after decoding but got %s (%v)", again, err)
+	}
+	if names := decoded.Entries[0].OrderedFieldNames(); strings.Join(names, ",") != "title,publisher,year" {
+		t.Errorf("Expected fields in order but got %v", names)
+	}
+	if data, err := json.Marshal(NewBibTex()); err != nil || string(data) != `{"entries":[],"strings":{},"preambles":[]}` {
+		t.Errorf("Unexpected JSON of empty bibliography %s (%v)", data, err)
+	}
+	if err := json.Unmarshal([]byte(`{"entries":[{"fields":{"a":1}}]}`), &decoded); !errors.Is(err, ErrInvalidField) {
+		t.Errorf("Expected ErrInvalidField but got %v", err)
+	}
+}
+
+// Tests resolving string variables defined in terms of other variables.
+func TestResolveStringVars(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{c = b # " and more"}
+@string{a = "A"}
+@string{b = a # " extra"}
+@misc{x, note = c, title = b # "!"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bib.ResolveStringVars(); err != nil {
+		t.Fatal(err)
+	}
+	for key, expected := range map[string]string{"a": "A", "b": "A extra", "c": "A extra and more"} {
+		if val, ok := bib.StringVar[key].Value.(BibConst); !ok || string(val) != expected {
+			t.Errorf("Expected %s = %q but got %#v", key, expected, bib.StringVar[key].Value)
+		}
+	}
+	if note := bib.Entries[0].Fields["note"].(*BibVar); note.Value != NewBibConst("A extra and more") {
+		t.Errorf("Unexpected note %#v", note)
+	}
+	if raw := bib.Entries[0].Fields["title"].RawString(); raw != "b # {!}" {
+		t.Errorf("Expected references to be kept but got %s", raw)
+	}
+
+	bib, err = Parse(strings.NewReader(`@string{short = {ACM}}
+@string{early = short # { Press}}
+@misc{a, note = short, title = early}
+@string{short = {IEEE}}
+@misc{b, note = short, title = later}
+@string{later = short # { Press}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bib.ResolveStringVars(); err != nil {
+		t.Fatal(err)
+	}
+	for key, expected := range map[string]string{"short": "IEEE", "early": "ACM Press", "later": "IEEE Press"} {
+		if val := bib.StringVar[key].Value; val != NewBibConst(expected) {
+			t.Errorf("Expected %s = %q but got %#v", key, expected, val)
+		}
+	}
+	for i, expected := range [][2]string{{"ACM", "ACM Press"}, {"IEEE", "IEEE Press"}} {
+		entry := bib.Entries[i]
+		if note := entry.Fields["note"].(*BibVar); note.Value != NewBibConst(expected[0]) {
+			t.Errorf("Expected note %q in %s but got %#v", expected[0], entry.CiteName, note)
+		}
+		if title := entry.Fields["title"].(*BibVar); title.Value != NewBibConst(expected[1]) {
+			t.Errorf("Expected title %q in %s but got %#v", expected[1], entry.CiteName, title)
+		}
+	}
+
+	bib = NewBibTex()
+	bib.AddStringVar("a", &BibVar{Key: "b"})
+	bib.AddStringVar("b", &BibVar{Key: "a"})
+	bib.AddStringVar("c", &BibVar{Key: "b"})
+	bib.AddStringVar("d", NewBibComposite(&BibVar{Key: "e"}))
+	bib.AddStringVar("f", NewBibConst("F"))
+	err = bib.ResolveStringVars()
+	if !errors.Is(err, ErrStringVarCycle) || !errors.Is(err, ErrUnknownStringVar) {
+		t.Errorf("Expected ErrStringVarCycle and ErrUnknownStringVar but got %v", err)
+	}
+	if _, ok := bib.StringVar["c"].Value.(*BibVar); !ok {
+		t.Errorf("Expected c to be unresolved but got %#v", bib.StringVar["c"].Value)
+	}
+	if bib.StringVar["f"].Value != NewBibConst("F") {
+		t.Errorf("Unexpected f %#v", bib.StringVar["f"].Value)
+	}
+}
+
+// Tests that transforms leave verbatim fields unchanged, and normalising URLs.
+func TestVerbatimFields(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@misc{a,
+  title = {A   title},
+  url = { <https://example.com/a  b.pdf> },
+  doi = {10.1000/a  b},
+  file = {a  b.pdf},
+  pdf = {a  b.pdf}
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	VerbatimFields["pdf"] = true
+	defer delete(VerbatimFields, "pdf")
+	if err := bib.Apply(NormalizeWhitespace); err != nil {
+		t.Fatal(err)
+	}
+	entry := bib.Entries[0]
+	for name, expected := range map[string]string{
+		"title": "A title",
+		"url":   " <https://example.com/a  b.pdf> ",
+		"doi":   "10.1000/a  b",
+		"file":  "a  b.pdf",
+		"pdf":   "a  b.pdf",
+	} {
+		if val, _ := entry.DisplayField(name); val != expected {
+			t.Errorf("Expected %s %q but got %q", name, expected, val)
+		}
+	}
+	if err := bib.Apply(NormalizeURLs); err != nil {
+		t.Fatal(err)
+	}
+	if url, _ := entry.DisplayField("url"); url != "https://example.com/a%20%20b.pdf" {
+		t.Errorf("Unexpected url %q", url)
+	}
+}
+
+// Tests parsing the page range of entries.
+func TestPages(t *testing.T) {
+	for pages, expected := range map[string][2]int{
+		"123--456":   {123, 456},
+		"123-456":    {123, 456},
+		" 12 -- 15 ": {12, 15},
+		"123":        {123, 123},
+	} {
+		entry := NewBibEntry("article", "a")
+		entry.AddField("pages", NewBibConst(pages))
+		if start, end, err := entry.Pages(); err != nil || start != expected[0] || end != expected[1] {
+			t.Errorf("Expected pages %d to %d for %q but got %d to %d (%v)", expected[0], expected[1], pages, start, end, err)
+		}
+	}
+	for _, pages := range []string{"xii", "12--", "12--xv"} {
+		entry := NewBibEntry("article", "a")
+		entry.AddField("pages", NewBibConst(pages))
+		if _, _, err := entry.Pages(); !errors.Is(err, ErrInvalidField) {
+			t.Errorf("Expected ErrInvalidField for %q but got %v", pages, err)
+		}
+	}
+	if _, _, err := NewBibEntry("article", "a").Pages(); !errors.Is(err, ErrMissingField) {
+		t.Errorf("Expected ErrMissingField but got %v", err)
+	}
+}
+
+// Tests splitting the entries with a predicate.
+func TestPartition(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{acm = "ACM"}
+@preamble{"\relax"}
+@article{a, publisher = acm}
+@book{b,}
+@article{c,}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	articles, rest := bib.Partition(func(entry *BibEntry) bool { return entry.Type == "article" })
+	if names := strings.Join(citeNames(articles), ","); names != "a,c" {
+		t.Errorf("Expected articles a,c but got %s", names)
+	}
+	if names := strings.Join(citeNames(rest), ","); names != "b" {
+		t.Errorf("Expected rest b but got %s", names)
+	}
+	for _, part := range []*BibTex{articles, rest} {
+		if len(part.Preambles) != 1 || part.StringVar["acm"] != bib.StringVar["acm"] {
+			t.Errorf("Expected preambles and string variables of bib but got %s", part.RawString())
+		}
+	}
+	if len(bib.Entries) != 3 {
+		t.Errorf("Expected bib to be unchanged but got %d entries", len(bib.Entries))
+	}
+}
+
+// Tests the textual diff of two bibliographies.
+func TestDiffString(t *testing.T) {
+	old, err := Parse(strings.NewReader(`@misc{old, title = {Old}, author = {A}}
+@article{knuth, year = 1984, title = {Literate Programming}, note = {x}, Pages = {1--2}}
+@misc{same, title = {Same}}
+@misc{retyped, title = {T}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated, err := Parse(strings.NewReader(`@book{new, title = {New}}
+@misc{SAME, title = {Same}}
+@article{knuth, title = {Literate Programming}, year = 1986, Abstract = {A}, pages = {1--2}}
+@book{retyped, title = {T}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `-@misc{old}
+-  author = {A}
+-  title = {Old}
+ @article{knuth}
++  Abstract = {A}
+-  note = {x}
+-  year = {1984}
++  year = {1986}
+-@misc{retyped}
++@book{retyped}
++@book{new}
++  title = {New}
+`
+	for i := 0; i < 3; i++ {
+		if diff := old.DiffString(updated); diff != expected {
+			t.Fatalf("Unexpected diff\n%s\nexpected\n%s", diff, expected)
+		}
+	}
+	if diff := old.DiffString(old); diff != "" {
+		t.Errorf("Expected no diff but got\n%s", diff)
+	}
+}
+
+// Tests iterating over the entries until an error.
+func TestForEach(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@misc{a, year = 2000}
+@misc{b, year = {n.d.}}
+@misc{c, year = 2001}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var visited []string
+	err = bib.ForEach(func(entry *BibEntry) error {
+		visited = append(visited, entry.CiteName)
+		_, err := entry.GetYear()
+		return err
+	})
+	if !errors.Is(err, ErrInvalidField) || strings.Join(visited, ",") != "a,b" {
+		t.Errorf("Expected to stop at b with ErrInvalidField but visited %v (%v)", visited, err)
+	}
+	visited = nil
+	if err := bib.ForEach(func(entry *BibEntry) error {
+		visited = append(visited, entry.CiteName)
+		return nil
+	}); err != nil || len(visited) != 3 {
+		t.Errorf("Expected all entries visited but got %v (%v)", visited, err)
+	}
+}
+
+// Tests validating required fields, including of a registered entry type.
+func TestValidate(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@book{a, editor = {E}, title = {T}, publisher = {P}, year = 2000}
+@book{b, title = {T}, publisher = { }, year = 2000}
+@online{c, title = {T}, url = {https://example.com}}
+@custom{d,}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bib.Entries[0].Validate(); err != nil {
+		t.Errorf("Expected a valid book but got %v", err)
+	}
+	err = bib.Validate()
+	if !errors.Is(err, ErrMissingField) || err.Error() != "Missing field: author/editor in b\nMissing field: publisher in b" {
+		t.Errorf("Unexpected errors %v", err)
+	}
+
+	RegisterEntryType("Online", [][]string{{"author", "editor", "organization"}, {"title"}, {"url"}, {"urldate"}})
+	defer delete(requiredFields, "online")
+	if fields := bib.Entries[2].RequiredFields(false); strings.Join(fields, ",") != "author/editor/organization,title,url,urldate" {
+		t.Errorf("Unexpected required fields %v", fields)
+	}
+	err = bib.Entries[2].Validate()
+	if err == nil || err.Error() != "Missing field: author/editor/organization in c\nMissing field: urldate in c" {
+		t.Errorf("Unexpected errors %v", err)
+	}
+	bib.Entries[2].AddField("organization", NewBibConst("O"))
+	bib.Entries[2].AddField("urldate", NewBibConst("2024-01-01"))
+	if err := bib.Entries[2].Validate(); err != nil {
+		t.Errorf("Expected a valid online entry but got %v", err)
+	}
+	if err := bib.Entries[3].Validate(); err != nil {
+		t.Errorf("Expected no errors for an unknown type but got %v", err)
+	}
+}
+
+// Tests that looking up entries by key sees changes to the entries.
+func TestEntryByKeyIndex(t *testing.T) {
+	bib := NewBibTex()
+	for i := 0; i < 100; i++ {
+		if err := bib.AddEntryUnique(NewBibEntry("misc", fmt.Sprintf("Key%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if entry := bib.EntryByKey("key42"); entry == nil || entry.CiteName != "Key42" {
+		t.Fatalf("Expected Key42 but got %v", entry)
+	}
+	if err := bib.AddEntryUnique(NewBibEntry("misc", "KEY42")); !errors.Is(err, ErrDuplicateCiteKey) {
+		t.Errorf("Expected ErrDuplicateCiteKey but got %v", err)
+	}
+	bib.Entries = append(bib.Entries[:10], bib.Entries[11:]...) // Remove Key10.
+	if entry := bib.EntryByKey("key10"); entry != nil {
+		t.Errorf("Expected removed entry not to be found but got %v", entry)
+	}
+	if entry := bib.EntryByKey("key11"); entry == nil || entry.CiteName != "Key11" {
+		t.Errorf("Expected Key11 but got %v", entry)
+	}
+	bib.Entries[0], bib.Entries[1] = bib.Entries[1], bib.Entries[0]
+	if entry := bib.EntryByKey("key1"); entry == nil || entry.CiteName != "Key1" {
+		t.Errorf("Expected Key1 after swapping but got %v", entry)
+	}
+	bib.Rekey(func(entry *BibEntry, _ map[string]bool) string { return "new" + entry.CiteName })
+	if bib.EntryByKey("key5") != nil || bib.EntryByKey("newkey5") == nil {
+		t.Errorf("Expected entries to be found by their new keys")
+	}
+}
+
+// Tests reporting fields longer than their limit.
+func TestValidateFieldLengths(t *testing.T) {
+	entry := NewBibEntry("article", "a")
+	entry.AddField("title", NewBibConst("Ünïcödé title"))
+	entry.AddField("abstract", NewBibConst("Short"))
+	entry.AddField("note", NewBibConst("A long note"))
+	errs := entry.ValidateFieldLengths(map[string]int{"title": 10, "abstract": 10, "note": 5, "pages": 1})
+	expected := []ValidationError{
+		{CiteName: "a", Field: "note", Limit: 5, Length: 11},
+		{CiteName: "a", Field: "title", Limit: 10, Length: 13},
+	}
+	if !reflect.DeepEqual(errs, expected) {
+		t.Errorf("Expected %v but got %v", expected, errs)
+	}
+}
+
+// Tests that fields are written in the order they were parsed, and reordering
+// them canonically.
+func TestFieldOrder(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{a, year = 2020, title = {T}, zzz = {Z}, author = {A}, abc = {B}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "@article{a,\n  year = 2020,\n  title = {T},\n  zzz = {Z},\n  author = {A},\n  abc = {B}\n}\n"
+	if s := bib.RawString(); s != expected {
+		t.Errorf("Expected parsed order\n%s\nbut got\n%s", expected, s)
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).FieldOrder([]string{"title", "missing"}).Encode(bib); err != nil {
+		t.Fatal(err)
+	}
+	expected = "@article{a,\n  title = {T},\n  abc = {B},\n  author = {A},\n  year = 2020,\n  zzz = {Z}\n}\n"
+	if buf.String() != expected {
+		t.Errorf("Expected FieldOrder\n%s\nbut got\n%s", expected, buf.String())
+	}
+	bib.SortFieldsCanonical()
+	expected = "@article{a,\n  author = {A},\n  title = {T},\n  year = 2020,\n  abc = {B},\n  zzz = {Z}\n}\n"
+	if s := bib.RawString(); s != expected {
+		t.Errorf("Expected canonical order\n%s\nbut got\n%s", expected, s)
+	}
+}
+
+// Tests extracting the abstracts of entries.
+func TestExtractAbstracts(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{pre = "We show"}
+@article{a, abstract = pre # { that it works.}}
+@article{b, title = {No abstract}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"a": "We show that it works."}
+	if abstracts := bib.ExtractAbstracts(); !reflect.DeepEqual(abstracts, expected) {
+		t.Errorf("Expected %v but got %v", expected, abstracts)
+	}
+	if abstracts := (*BibTex)(nil).ExtractAbstracts(); len(abstracts) != 0 {
+		t.Errorf("Expected no abstracts for nil BibTex but got %v", abstracts)
+	}
+}
+
+// Tests ISBN and ISSN checksums.
+func TestValidateIdentifiers(t *testing.T) {
+	for s, valid := range map[string]bool{
+		"0-201-03801-3":     true,
+		"0 201 03801 3":     true,
+		"0-8044-2957-x":     true,
+		"0-201-03801-4":     false,
+		"978-0-306-40615-7": true,
+		"978-0-306-40615-6": false,
+		"97803064061X":      false,
+		"ISBN 0201038013":   false,
+		"":                  false,
+	} {
+		if ValidateISBN(s) != valid {
+			t.Errorf("Expected ValidateISBN(%q) to be %v", s, valid)
+		}
+	}
+	if s := NormalizeISBN("0-8044-2957-x"); s != "080442957X" {
+		t.Errorf("Expected normalized ISBN 080442957X but got %q", s)
+	}
+	if s := NormalizeISBN("0-201-03801-4"); s != "0-201-03801-4" {
+		t.Errorf("Expected invalid ISBN to be unchanged but got %q", s)
+	}
+	for s, valid := range map[string]bool{"0317-8471": true, "2434-561X": true, "0317-8472": false, "03178471X": false} {
+		if ValidateISSN(s) != valid {
+			t.Errorf("Expected ValidateISSN(%q) to be %v", s, valid)
+		}
+	}
+
+	bib, err := Parse(strings.NewReader(`@book{a, isbn = {0-201-03801-3}}
+@article{b, issn = {0317-8472}}
+@book{c, isbn = {123}, issn = {2434-561X}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := bib.ValidateIdentifiers()
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 invalid identifiers but got %v", errs)
+	}
+	for i, s := range []string{"b: issn", "c: isbn"} {
+		if !errors.Is(errs[i], ErrInvalidIdentifier) || !strings.Contains(errs[i].Error(), s) {
+			t.Errorf("Expected error for %s but got %v", s, errs[i])
+		}
+	}
+}
+
+// Tests rewriting semicolon and comma separated names to use "and".
+func TestNormalizeAuthorSeparators(t *testing.T) {
+	tests := [][2]string{
+		{"Knuth, Donald; Lamport, Leslie", "Knuth, Donald and Lamport, Leslie"},
+		{"Donald Knuth; Leslie Lamport;", "Donald Knuth and Leslie Lamport"},
+		{"Donald Knuth, Leslie Lamport, and Alan Turing", "Donald Knuth and Leslie Lamport and Alan Turing"},
+		{"Knuth, Donald E.; Leslie Lamport and A. Turing", "Knuth, Donald E. and Leslie Lamport and A. Turing"},
+		{"{Barnes and Noble}; Knuth, Jr., Donald", "{Barnes and Noble} and Knuth, Jr., Donald"},
+		{"{Research Group, Inc.}; {Other Group, Ltd.}", "{Research Group, Inc.} and {Other Group, Ltd.}"},
+		{"Knuth, Donald E.", "Knuth, Donald E."},
+		{"van Beethoven, Ludwig", "van Beethoven, Ludwig"},
+		{"Donald E. Knuth, Jr.", "Donald E. Knuth, Jr."},
+		{"Knuth, Donald and Lamport, Leslie", "Knuth, Donald and Lamport, Leslie"},
+		{"D.~Knuth  and   L. Lamport", "D.~Knuth  and   L. Lamport"},
+		{"Garcia Marquez, Gabriel Jose", "Garcia Marquez, Gabriel Jose"},
+		{"van der Berg, Anna Maria", "van der Berg, Anna Maria"},
+		{"Garcia Marquez, Gabriel Jose; van der Berg, Anna Maria", "Garcia Marquez, Gabriel Jose and van der Berg, Anna Maria"},
+		{"Garcia Marquez, Gabriel Jose and Alan Turing", "Garcia Marquez, Gabriel Jose and Alan Turing"},
+		{"Alan Turing, Donald Knuth, Leslie Lamport", "Alan Turing and Donald Knuth and Leslie Lamport"},
+	}
+	bib := NewBibTex()
+	for i, test := range tests {
+		entry := NewBibEntry("article", fmt.Sprint(i))
+		entry.AddField("author", NewBibConst(test[0]))
+		entry.AddField("editor", NewBibConst(test[0]))
+		bib.AddEntry(entry)
+	}
+	bib.NormalizeAuthorSeparators()
+	for i, test := range tests {
+		for _, field := range []string{"author", "editor"} {
+			if got := bib.Entries[i].Fields[field].String(); got != test[1] {
+				t.Errorf("Expected %s %q to become %q but got %q", field, test[0], test[1], got)
+			}
+		}
+	}
+}
+
+// Tests saving and loading a BibTex with encoding/gob.
+func TestGob(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{acm = {ACM}}
+@string{jacm = {J. } # acm}
+@preamble{"\newcommand{\noop}[1]{}"}
+@article{a, year = 2020, journal = jacm, title = {Title}}
+@misc{b, publisher = acm # { Press}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(bib); err != nil {
+		t.Fatal(err)
+	}
+	var decoded BibTex
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.RawString() != bib.RawString() {
+		t.Errorf("Expected\n%s\nbut got\n%s", bib.RawString(), decoded.RawString())
+	}
+	if decoded.String() != bib.String() {
+		t.Errorf("Expected\n%s\nbut got\n%s", bib.String(), decoded.String())
+	}
+	if decoded.Entries[0].Fields["journal"] != decoded.StringVar["jacm"] {
+		t.Error("Expected string variables to be shared with StringVar")
+	}
+
+	buf.Reset()
+	if err := gob.NewEncoder(&buf).Encode(bib.Entries[1]); err != nil {
+		t.Fatal(err)
+	}
+	var entry BibEntry
+	if err := gob.NewDecoder(&buf).Decode(&entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.String() != bib.Entries[1].String() || entry.Fields["publisher"].String() != "ACM Press" {
+		t.Errorf("Expected\n%s\nbut got\n%s", bib.Entries[1], &entry)
+	}
+}
+
+// Tests that references to a redefined string variable keep their definition
+// through gob.
+func TestGobRedefinedStringVar(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@misc{early, publisher = ieee}
+@string{acm = {ACM}}
+@misc{a, publisher = acm # { Press}}
+@string{acm = {Association for Computing Machinery}}
+@string{ieee = {IEEE}}
+@string{ieee = {IEEE CS}}
+@misc{b, publisher = acm}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(bib); err != nil {
+		t.Fatal(err)
+	}
+	var decoded BibTex
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := decoded.ResolveStringVars(); err != nil {
+		t.Fatal(err)
+	}
+	for i, expected := range []string{"IEEE CS", "ACM Press", "Association for Computing Machinery"} {
+		if got := decoded.Entries[i].Fields["publisher"].String(); got != expected {
+			t.Errorf("Expected publisher of %s to be %q but got %q", decoded.Entries[i].CiteName, expected, got)
+		}
+	}
+	if decoded.Entries[2].Fields["publisher"] != decoded.StringVar["acm"] {
+		t.Error("Expected references to the last definition to be shared with StringVar")
+	}
+}
+
+// Tests that gob encoding reports cyclic string variables.
+func TestGobStringVarCycle(t *testing.T) {
+	bib := NewBibTex()
+	bib.AddStringVar("a", nil)
+	bib.AddStringVar("b", nil)
+	bib.StringVar["a"].Value = NewBibComposite(NewBibConst("x"))
+	bib.StringVar["a"].Value.(*BibComposite).Append(bib.StringVar["b"])
+	bib.StringVar["b"].Value = bib.StringVar["a"]
+	entry := NewBibEntry("misc", "c")
+	entry.AddField("note", bib.StringVar["a"])
+	bib.AddEntry(entry)
+	if err := gob.NewEncoder(io.Discard).Encode(bib); !errors.Is(err, ErrStringVarCycle) {
+		t.Errorf("Expected string variable cycle error but got %v", err)
+	}
+	if err := gob.NewEncoder(io.Discard).Encode(entry); !errors.Is(err, ErrStringVarCycle) {
+		t.Errorf("Expected string variable cycle error but got %v", err)
+	}
+}
+
+// Tests that String output parses without the string variables it uses.
+func TestStringSelfContained(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{jan = "January"}
+@string{feb = "February"}
+@article{a, month = jan # { and } # feb, year = 2020}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := NewBibEntry("misc", "b")
+	entry.AddField("month", &BibVar{Key: "mar"})
+	entry.AddField("title", NewBibConst("Un}balanced {title"))
+	bib.AddEntry(entry)
+
+	s := bib.String()
+	if strings.Contains(s, "@string") {
+		t.Errorf("Expected no string variables in\n%s", s)
+	}
+	parsed, err := Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("Expected String output to parse on its own: %v\n%s", err, s)
+	}
+	for _, test := range []struct{ key, field, expected string }{
+		{"a", "month", "January and February"},
+		{"a", "year", "2020"},
+		{"b", "month", ""},
+		{"b", "title", "Unbalanced {title}"},
+	} {
+		for _, e := range parsed.Entries {
+			if e.CiteName != test.key {
+				continue
+			}
+			if val := e.Fields[test.field].String(); val != test.expected {
+				t.Errorf("Expected %s %s to be %q but got %q", test.key, test.field, test.expected, val)
+			}
+		}
+	}
+}
+
+// Tests replacing a field and getting its previous value.
+func TestSetField(t *testing.T) {
+	entry := NewBibEntry("article", "a")
+	if old := entry.SetField("title", NewBibConst("First")); old != nil {
+		t.Errorf("Expected no previous value but got %v", old)
+	}
+	entry.AddField("year", NewBibConst("2020"))
+	if old := entry.SetField(" title ", NewBibConst("Second")); old != NewBibConst("First") {
+		t.Errorf("Expected previous value First but got %v", old)
+	}
+	if title := entry.Fields["title"].String(); title != "Second" {
+		t.Errorf("Expected title Second but got %s", title)
+	}
+	if names := entry.OrderedFieldNames(); !reflect.DeepEqual(names, []string{"title", "year"}) {
+		t.Errorf("Expected replaced field to keep its position but got %v", names)
+	}
+}
+
+// Tests resolving the members of a biblatex @set entry.
+func TestSetMembers(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@set{s, entryset = {a, b}}
+@article{a, title = {A}}
+@article{b, title = {B}, related = {c}}
+@set{broken, entryset = {a,missing,gone}}
+@misc{c, title = {C}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	members, err := bib.SetMembers("s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 2 || members[0] != bib.Entries[1] || members[1] != bib.Entries[2] {
+		t.Errorf("Expected members a and b but got %v", members)
+	}
+	_, err = bib.SetMembers("broken")
+	if !errors.Is(err, ErrUnknownCiteKey) || !strings.Contains(err.Error(), "missing") || !strings.Contains(err.Error(), "gone") {
+		t.Errorf("Expected unknown members missing and gone but got %v", err)
+	}
+	if _, err := bib.SetMembers("c"); !errors.Is(err, ErrMissingField) {
+		t.Errorf("Expected missing entryset but got %v", err)
+	}
+	if _, err := bib.SetMembers("none"); !errors.Is(err, ErrUnknownCiteKey) {
+		t.Errorf("Expected unknown set but got %v", err)
+	}
+}
+
+// Tests rewriting the values of a field in all entries.
+func TestTransformFieldValues(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{a, title = {{The} {TeX}book}}
+@article{b, title = {Plain}}
+@article{c, year = 2020}
+@article{d, title = {}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := bib.TransformFieldValues("title", func(s BibString) BibString {
+		if s.String() == "" {
+			return nil
+		}
+		return NewBibConst(stripBraces(s.String()))
+	})
+	if n != 2 {
+		t.Errorf("Expected 2 entries changed but got %d", n)
+	}
+	if title := bib.Entries[0].Fields["title"].String(); title != "The TeXbook" {
+		t.Errorf("Expected braces stripped from title but got %q", title)
+	}
+	if _, ok := bib.Entries[2].Fields["title"]; ok {
+		t.Error("Expected no title added to entry without title")
+	}
+	if _, ok := bib.Entries[3].Fields["title"]; ok {
+		t.Error("Expected empty title removed")
+	}
+}
+
+// Tests the Encoder options and the PrettyString preset.
+func TestEncoderOptions(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{acm = {ACM}}
+@preamble{{\noop}}
+@article{a, title = {R&D 50% {Faster}}, publisher = acm # { Press}, year = 2020}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "@article{a,\n  title     = {R&D 50% {Faster}},\n  publisher = \"ACM Press\",\n  year      = 2020,\n}\n"
+	if s := bib.PrettyString(); s != expected {
+		t.Errorf("Expected PrettyString\n%s\nbut got\n%s", expected, s)
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Indent("\t").QuoteStyle(DoubleQuotes).Encode(bib); err != nil {
+		t.Fatal(err)
+	}
+	expected = "@string{acm = \"ACM\"}\n@preamble{\"\\noop\"}\n@article{a,\n\ttitle = {R&D 50% {Faster}},\n\tpublisher = acm # \" Press\",\n\tyear = 2020\n}\n"
+	if buf.String() != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, buf.String())
+	}
+	buf.Reset()
+	if err := NewEncoder(&buf).ExpandStrings(true).OmitPreambles(true).LaTeXEscape(true).Encode(bib); err != nil {
+		t.Fatal(err)
+	}
+	expected = "@article{a,\n  title = {R\\&D 50\\% {Faster}},\n  publisher = {ACM Press},\n  year = 2020\n}\n"
+	if buf.String() != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, buf.String())
+	}
+	if s := latexEscape(`a\&b_c`); s != `a\&b\_c` {
+		t.Errorf("Expected escaped characters to stay escaped but got %s", s)
+	}
+}
+
+// Tests the built-in lint rules.
+func TestLint(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{a, title = {A Study of {DNA} Sequences}, doi = {10.1/x}, abstract = {A}, pages = {12--15}}
+@article{b, title = {A Study of the Effects of sequencing on DNA}, abstract = {B}, pages = {15-12}}
+@book{c, title = {The {TeX}book}, pages = {xii}}
+@misc{a, abstract = {Again}, pages = {1–2}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var issues []string
+	for _, issue := range bib.Lint() {
+		issues = append(issues, issue.CiteName+" "+issue.Rule)
+	}
+	expected := []string{
+		"b MissingDOI",
+		"b InconsistentCapitalization",
+		"b UnprotectedAcronyms",
+		"b NumericPageRange",
+		"b PageRangeFormat",
+		"c MissingAbstract",
+		"c NumericPageRange",
+		"a DuplicateCiteKey",
+		"a PageRangeFormat",
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Errorf("Expected issues\n%v\nbut got\n%v", expected, issues)
+	}
+	titleCase := NewBibEntry("book", "d")
+	titleCase.AddField("title", NewBibConst("Programming with Types from First Principles into Practice"))
+	if issues := InconsistentCapitalization(titleCase); len(issues) != 0 {
+		t.Errorf("Expected minor words in title case to be ignored but got %v", issues)
+	}
+	issues = nil
+	for _, issue := range bib.Lint(MissingAbstract) {
+		issues = append(issues, issue.String())
+	}
+	if expected := []string{"c: abstract: missing abstract (MissingAbstract)"}; !reflect.DeepEqual(issues, expected) {
+		t.Errorf("Expected issues %v but got %v", expected, issues)
+	}
+}
+
+// Tests that cite names are matched case-insensitively but keep their casing.
+func TestEntryByKey(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{Foo2020, title = {Foo}}
+@article{foo2020, title = {Duplicate}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry := bib.EntryByKey("foo2020"); entry != bib.Entries[0] {
+		t.Errorf("Expected foo2020 to find Foo2020 but got %v", entry)
+	}
+	if entry := bib.EntryByKey("FOO2020"); entry != bib.Entries[0] {
+		t.Errorf("Expected FOO2020 to find Foo2020 but got %v", entry)
+	}
+	if entry := bib.EntryByKey("bar"); entry != nil {
+		t.Errorf("Expected no entry but got %v", entry)
+	}
+	if !strings.HasPrefix(bib.RawString(), "@article{Foo2020,\n") {
+		t.Errorf("Expected original casing in output:\n%s", bib.RawString())
+	}
+	issues := bib.Lint(DuplicateCiteKey())
+	if len(issues) != 1 || issues[0].CiteName != "foo2020" {
+		t.Errorf("Expected foo2020 to be a duplicate but got %v", issues)
+	}
+}
+
+// Tests copying fields from one entry to another.
+func TestCopyFieldsTo(t *testing.T) {
+	book := NewBibEntry("book", "book")
+	book.AddField("title", NewBibConst("Book"))
+	book.AddField("publisher", NewBibConst("ACM"))
+	book.AddField("year", NewBibConst("2020"))
+	chapter := NewBibEntry("incollection", "chapter")
+	chapter.AddField("title", NewBibConst("Chapter"))
+	book.CopyFieldsTo(chapter, "publisher", "title", "missing")
+	if names := chapter.OrderedFieldNames(); !reflect.DeepEqual(names, []string{"title", "publisher"}) {
+		t.Errorf("Expected title and publisher but got %v", names)
+	}
+	if title := chapter.Fields["title"].String(); title != "Chapter" {
+		t.Errorf("Expected existing title to be kept but got %s", title)
+	}
+	book.CopyFieldsTo(chapter)
+	if names := chapter.OrderedFieldNames(); !reflect.DeepEqual(names, []string{"title", "publisher", "year"}) {
+		t.Errorf("Expected all fields copied but got %v", names)
+	}
+}
+
+// Tests errors for values and entries that are not terminated.
+func TestUnterminated(t *testing.T) {
+	tests := []struct {
+		input  string
+		err    error
+		line   int
+		column int
+	}{
+		{"@article{a,\n  title = {Unbalanced {brace}\n}\n", ErrUnterminatedValue, 2, 11},
+		{"@article{a,\n  title = {Unbalanced {brace},\n}\n@article{b, title = {B}}\n", ErrUnterminatedValue, 2, 11},
+		{"@article{a,\n  title = \"Unterminated,\n  year = 2020\n}\n@article{b, title = {B}}\n", ErrUnterminatedValue, 2, 11},
+		{"@article{a, title = \"Unterminated}", ErrUnterminatedValue, 1, 21},
+		{"@article{a,\n  title = {Title}\n\n@article{b, title = {B}}\n", ErrUnterminatedEntry, 1, 9},
+		{"@article{a,\n  title = {Title},\n", ErrUnterminatedEntry, 1, 9},
+	}
+	for _, test := range tests {
+		_, err := Parse(strings.NewReader(test.input))
+		var perr ParseError
+		if !errors.As(err, &perr) || !errors.Is(err, test.err) {
+			t.Errorf("Expected %v for %q but got %v", test.err, test.input, err)
+			continue
+		}
+		if perr.Line != test.line || perr.Column != test.column {
+			t.Errorf("Expected error at %d:%d for %q but got %v", test.line, test.column, test.input, err)
+		}
+		if expected := fmt.Sprintf("starting at line %d", test.line); !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to contain %q but got %v", expected, err)
+		}
+	}
+}
+
+// Tests getting the entries of a type.
+func TestEntriesOfType(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{a,} @Book{b,} @ARTICLE{c,}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range bib.EntriesOfType("Article") {
+		names = append(names, entry.CiteName)
+	}
+	if expected := []string{"a", "c"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected articles %v but got %v", expected, names)
+	}
+	if entries := bib.EntriesOfType("misc"); entries != nil {
+		t.Errorf("Expected nil but got %v", entries)
+	}
+}
+
+// Tests getting fields in raw and displayed form.
+func TestRawDisplayField(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{acm = {ACM}}
+@article{a, Publisher = acm # { Press}, title = {Title}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := bib.Entries[0]
+	if raw, ok := entry.RawField("publisher"); !ok || raw != "acm # { Press}" {
+		t.Errorf("Expected raw publisher but got %q (%v)", raw, ok)
+	}
+	if display, ok := entry.DisplayField("PUBLISHER"); !ok || display != "ACM Press" {
+		t.Errorf("Expected displayed publisher but got %q (%v)", display, ok)
+	}
+	if raw, ok := entry.RawField("title"); !ok || raw != "{Title}" {
+		t.Errorf("Expected raw title but got %q (%v)", raw, ok)
+	}
+	if _, ok := entry.DisplayField("year"); ok {
+		t.Error("Expected no year field")
+	}
+}
+
+// Tests trimming whitespace and enclosing braces from constants.
+func TestBibConstTrim(t *testing.T) {
+	for input, expected := range map[string]string{
+		"  Title\n":       "Title",
+		"\t{ {Title} } ":  "Title",
+		"{The} {TeX}book": "{The} {TeX}book",
+		" {Unbalanced ":   "{Unbalanced",
+		" Unicode space":  " Unicode space",
+		"":                "",
+	} {
+		if trimmed := NewBibConst(input).Trim(); trimmed != NewBibConst(expected) {
+			t.Errorf("Expected %q to be trimmed to %q but got %q", input, expected, trimmed)
+		}
+	}
+}
+
+// Tests that % comments are skipped between and inside entries.
+func TestPercentComments(t *testing.T) {
+	input := `% Comment before the first entry
+@article{a, % Comment before the first field
+  % Comment on its own line
+  title = {50% {off}}, % Comment between fields
+  note = "100% sure"
+  % Comment after the last field
+  , year = 2020 % Comment after the last field
+}
+%@article{commented, title = {Out}}
+@misc{b,}`
+	bib, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := citeNames(bib); !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Errorf("Expected entries a and b but got %v", names)
+	}
+	expected := "@article{a,\n  title = {50% {off}},\n  note = {100% sure},\n  year = 2020\n}\n"
+	if s := bib.Entries[0].String(); s != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, s)
+	}
+}
+
+// Tests visiting, replacing and removing fields with Walk.
+func TestWalk(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{a, title = {A}, note = {Remove}, year = 2020}
+@article{b, title = {B}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var visited []string
+	bib.Walk(func(entry *BibEntry, field string, value BibString) BibString {
+		visited = append(visited, entry.CiteName+"."+field)
+		switch field {
+		case "title":
+			delete(entry.Fields, "year") // Removed before it is visited.
+			entry.AddField("added", NewBibConst("Not visited"))
+			return NewBibConst(strings.ToLower(value.String()))
+		case "note":
+			return nil
+		}
+		return value
+	})
+	if expected := []string{"a.title", "a.note", "b.title"}; !reflect.DeepEqual(visited, expected) {
+		t.Errorf("Expected to visit %v but got %v", expected, visited)
+	}
+	expected := "@article{a,\n  title = {a},\n  added = {Not visited}\n}\n"
+	if s := bib.Entries[0].String(); s != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, s)
+	}
+}
+
+func ExampleBibTex_Walk() {
+	bib, _ := Parse(strings.NewReader(`@article{knuth1984,
+  title = {Literate Programming},
+  abstract = {The structure of a software program may be thought of as a web.},
+  year = 1984
+}`))
+	// Remove all abstracts.
+	bib.Walk(func(entry *BibEntry, field string, value BibString) BibString {
+		if field == "abstract" {
+			return nil
+		}
+		return value
+	})
+	fmt.Print(bib)
+	// Output:
+	// @article{knuth1984,
+	//   title = {Literate Programming},
+	//   year = 1984
+	// }
+}
+
+// Tests renaming cite keys and the references to them.
+func TestRekey(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@inproceedings{paper1, author = {Donald Knuth}, year = 1984, crossref = {Proc}}
+@proceedings{proc, title = {Proceedings}, year = 1984}
+@inproceedings{paper2, author = {Don Knuth}, year = 1984, crossref = {proc}}
+@set{s, entryset = {paper1, paper2}}
+@misc{keep, title = {Kept}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seen []int
+	bib.Rekey(func(entry *BibEntry, taken map[string]bool) string {
+		seen = append(seen, len(taken))
+		author, ok := entry.Fields["author"]
+		if !ok {
+			return ""
+		}
+		authors, _ := ParseAuthors(author.String())
+		key := strings.ToLower(authors[0].Last) + entry.Fields["year"].String()
+		for suffix := 'a'; taken[key]; suffix++ {
+			key = strings.TrimRight(key, "abcdefghijklmnopqrstuvwxyz") + string(suffix)
+		}
+		return key
+	})
+	if names, expected := citeNames(bib), []string{"knuth1984", "proc", "knuth1984a", "s", "keep"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected cite names %v but got %v", expected, names)
+	}
+	if expected := []int{0, 1, 2, 3, 4}; !reflect.DeepEqual(seen, expected) {
+		t.Errorf("Expected taken names to grow %v but got %v", expected, seen)
+	}
+	if crossref := bib.Entries[0].Fields["crossref"].String(); crossref != "Proc" {
+		t.Errorf("Expected unchanged crossref but got %s", crossref)
+	}
+	if entryset := bib.Entries[3].Fields["entryset"].String(); entryset != "knuth1984,knuth1984a" {
+		t.Errorf("Expected entryset to be renamed but got %s", entryset)
+	}
+	if members, err := bib.SetMembers("s"); err != nil || len(members) != 2 {
+		t.Errorf("Expected set members after renaming but got %v (%v)", members, err)
+	}
+}
+
+// Tests importing entries from CSV.
+func TestFromCSV(t *testing.T) {
+	input := `Title,Authors,Second Author,Year,Ignored
+"Hello, World",Donald Knuth,Leslie Lamport,1984,x
+Untitled,,,,
+`
+	bib, err := FromCSV(strings.NewReader(input), map[string]string{
+		"Title": "title", "Authors": "author", "Second Author": "author", "Year": "year",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "@misc{Knuth1984,\n  title = {Hello, World},\n  author = {Donald Knuth and Leslie Lamport},\n  year = 1984\n}\n" +
+		"@misc{ref,\n  title = {Untitled}\n}\n"
+	if s := bib.RawString(); s != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, s)
+	}
+
+	original, err := Parse(strings.NewReader(`@article{a, title = {Hello, "World"}, year = 2020} @misc{b,}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := original.ToCSV([]string{"title", "year"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bib, err = FromCSV(strings.NewReader(out), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bib.RawString() != original.RawString() {
+		t.Errorf("Expected ToCSV output to be read back as\n%s\nbut got\n%s", original.RawString(), bib.RawString())
+	}
+	bib, err = FromCSV(strings.NewReader("\ufeffTitle, Year \nExported,2021\n"), map[string]string{"Title": "title", "Year": "year"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "@misc{ref2021,\n  title = {Exported},\n  year = 2021\n}\n"; bib.RawString() != expected {
+		t.Errorf("Expected header with byte order mark to be mapped as\n%s\nbut got\n%s", expected, bib.RawString())
+	}
+	if _, err := FromCSV(strings.NewReader("a\n\"unterminated"), nil); err == nil {
+		t.Error("Expected error for malformed CSV")
+	}
+}
+
+// Tests converting LaTeX markup to Unicode.
+func TestLaTeXToUnicode(t *testing.T) {
+	for input, expected := range map[string]string{
+		`{\"U}ber die Stra{\ss}e`:            "Über die Straße",
+		`Erd\H{o}s and Ha\v{c}ek, \'{E}mile`: "Erdős and Haček, Émile",
+		`Ca\~na and \c c and {\'\i}`:         "Caña and ç and í",
+		`\emph{Important} \& 50\% off`:       "Important & 50% off",
+		"pp.~12--15 --- ``quoted''":          "pp. 12–15 — “quoted”",
+		`{\O}resund \AA ngstr\"om`:           "Øresund Ångström",
+	} {
+		if s := LaTeXToUnicode(input); s != expected {
+			t.Errorf("Expected %q to be converted to %q but got %q", input, expected, s)
+		}
+	}
+}
+
+// Tests that math is kept verbatim when converting LaTeX to Unicode.
+func TestLaTeXToUnicodeMath(t *testing.T) {
+	for input, expected := range map[string][2]string{
+		`Sorting in $O(n\log n)$ time`:  {`Sorting in $O(n\log n)$ time`, `Sorting in O(n\log n) time`},
+		`\'{E}t\'e $\'e$ \(x^2--y\)\"o`: {`Été $\'e$ \(x^2--y\)ö`, `Été \'e x^2--yö`},
+		`$$\sum_i$$ and \[a~b\]`:        {`$$\sum_i$$ and \[a~b\]`, `\sum_i and a~b`},
+		`Costs \$5 and \$10`:            {`Costs $5 and $10`, `Costs $5 and $10`},
+		`$\$1$ or $x`:                   {`$\$1$ or $x`, `\$1 or $x`},
+	} {
+		if s := LaTeXToUnicode(input); s != expected[0] {
+			t.Errorf("Expected %q to be converted to %q but got %q", input, expected[0], s)
+		}
+		if s := latexToUnicode(input, true); s != expected[1] {
+			t.Errorf("Expected %q without math delimiters to be converted to %q but got %q", input, expected[1], s)
+		}
+	}
+	bib, err := Parse(strings.NewReader(`@article{a, title = {An $O(n)$ {\'e}t{\'e}}, journal = {J}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md := bib.ToMarkdownWithOptions(APA, ExportOptions{StripMathDelimiters: true}); !strings.Contains(md, "An O(n) été") {
+		t.Errorf("Expected math without delimiters in %q", md)
+	}
+	if md := bib.ToMarkdown(APA); !strings.Contains(md, "An $O(n)$ été") {
+		t.Errorf("Expected math with delimiters in %q", md)
+	}
+	if html, err := bib.ToHTMLWithOptions("{{range .}}{{index .Fields \"title\"}}{{end}}", ExportOptions{StripMathDelimiters: true}); err != nil || !strings.HasPrefix(html, "An O(n) ") {
+		t.Errorf("Unexpected HTML %q (%v)", html, err)
+	}
+}
+
+// Tests the entry views for templates.
+func TestViews(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{b, author = {Lamport, Leslie}, title = {{\LaTeX}: A <Document> System}, year = 1986, journal = {J. Comp.}, doi = {doi:10.1000/x y}}
+@inproceedings{a, author = {Paul Erd{\H{o}}s and others}, title = {Graphs}, year = 1959, booktitle = {Proc.}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	views := bib.Views()
+	bib.Entries[0].AddField("title", NewBibConst("Changed"))
+	if len(views) != 2 || views[0].Key() != "a" || views[1].Key() != "b" {
+		t.Fatalf("Expected views sorted by author but got %v", views)
+	}
+	if s := views[0].AuthorsFormatted(); s != "Paul Erdős et al." {
+		t.Errorf("Unexpected authors %q", s)
+	}
+	if s := views[0].Venue(); s != "Proc." {
+		t.Errorf("Unexpected venue %q", s)
+	}
+	if s := views[1].Title(); s != "LaTeX: A <Document> System" {
+		t.Errorf("Expected title of view to be unaffected by changes but got %q", s)
+	}
+	if s := views[1].DOIURL(); s != "https://doi.org/10.1000/x%20y" {
+		t.Errorf("Unexpected DOI URL %q", s)
+	}
+	if s := views[0].DOIURL(); s != "" {
+		t.Errorf("Expected no DOI URL but got %q", s)
+	}
+	entry := NewBibEntry("misc", "c")
+	entry.AddField("url", NewBibConst("http://www.cs.example.edu/~knuth/a--b.pdf"))
+	entry.AddField("doi", NewBibConst("10.1000/a--b"))
+	verbatim := NewEntryView(entry)
+	if s := verbatim.URL(); s != "http://www.cs.example.edu/~knuth/a--b.pdf" {
+		t.Errorf("Expected URL to be kept verbatim but got %q", s)
+	}
+	if s := verbatim.DOIURL(); s != "https://doi.org/10.1000/a--b" {
+		t.Errorf("Expected DOI to be kept verbatim but got %q", s)
+	}
+	tmpl := template.Must(template.New("").Parse(`{{range .}}<a href="{{.DOIURL}}">{{.Title}}</a> {{.Year}}{{end}}`))
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, views[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if expected := `<a href="https://doi.org/10.1000/x%20y">LaTeX: A &lt;Document&gt; System</a> 1986`; buf.String() != expected {
+		t.Errorf("Expected %s but got %s", expected, buf.String())
+	}
+}
+
+// Tests normalising page range separators.
+func TestNormalizePageRange(t *testing.T) {
+	for input, expected := range map[string]string{
+		"12-15":         "12--15",
+		"12 – 15":       "12--15",
+		"12—15, 18-20":  "12--15, 18--20",
+		"12---15":       "12--15",
+		"12--15":        "12--15",
+		"12":            "12",
+		"e1234, 12--15": "e1234, 12--15",
+	} {
+		entry := NewBibEntry("article", "a")
+		entry.AddField("pages", NewBibConst(input))
+		changed := entry.NormalizePageRange()
+		if pages := entry.Fields["pages"].String(); pages != expected || changed != (input != expected) {
+			t.Errorf("Expected %q to be normalised to %q but got %q (changed %v)", input, expected, pages, changed)
+		}
+	}
+	if NewBibEntry("misc", "b").NormalizePageRange() {
+		t.Error("Expected no change without pages")
+	}
+}
+
+// Tests detecting redefined string variables.
+func TestStringVarRedefinition(t *testing.T) {
+	bib := NewBibTex()
+	if err := bib.AddStringVarChecked("acm", NewBibConst("ACM")); err != nil {
+		t.Fatal(err)
+	}
+	if err := bib.AddStringVarChecked("acm", NewBibConst("Other")); !errors.Is(err, ErrStringVarRedefined) {
+		t.Errorf("Expected redefinition error but got %v", err)
+	}
+	if v, _ := bib.GetStringVar("acm"); v.String() != "ACM" {
+		t.Errorf("Expected checked redefinition to keep ACM but got %s", v.String())
+	}
+
+	input := `@string{acm = {ACM}}
+@string{ieee = {IEEE}}
+@string{acm = {Association for Computing Machinery}}
+@misc{a, publisher = acm}`
+	var warnings []error
+	bib, err := ParseWithOptions(strings.NewReader(input), ParseOptions{OnWarning: func(err error) {
+		warnings = append(warnings, err)
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if publisher := bib.Entries[0].Fields["publisher"].String(); publisher != "Association for Computing Machinery" {
+		t.Errorf("Expected last definition to win but got %s", publisher)
+	}
+	var perr ParseError
+	if len(warnings) != 1 || !errors.Is(warnings[0], ErrStringVarRedefined) || !errors.As(warnings[0], &perr) || perr.Line != 3 {
+		t.Errorf("Expected a redefinition warning at line 3 but got %v", warnings)
+	}
+}
+
+// Tests adding entries with unique cite names.
+func TestAddEntryUnique(t *testing.T) {
+	bib := NewBibTex()
+	if err := bib.AddEntryUnique(NewBibEntry("misc", "Knuth84")); err != nil {
+		t.Fatal(err)
+	}
+	if err := bib.AddEntryUnique(NewBibEntry("book", "knuth84")); !errors.Is(err, ErrDuplicateCiteKey) {
+		t.Errorf("Expected duplicate cite key error but got %v", err)
+	}
+	if err := bib.AddEntryUnique(NewBibEntry("book", "lamport94")); err != nil {
+		t.Errorf("Expected no error but got %v", err)
+	}
+	if names := citeNames(bib); strings.Join(names, " ") != "Knuth84 lamport94" {
+		t.Errorf("Expected entries Knuth84 lamport94 but got %v", names)
+	}
+}
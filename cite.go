@@ -0,0 +1,89 @@
+package bibtex
+
+import (
+	"io"
+	"regexp"
+	"strings"
+)
+
+// citeCommand matches LaTeX citation commands such as \cite{a,b},
+// \citep[p.~3]{a}, \nocite{a} or \parencite*{a}, and \citation{a} in .aux
+// files. The first group is the list of cited keys.
+var citeCommand = regexp.MustCompile(`\\[a-zA-Z]*cit(?:e|ation)[a-zA-Z]*\*?\s*(?:\[[^\]]*\]\s*)*\{([^}]*)\}`)
+
+// CitedKeys returns the keys cited in the LaTeX source (or .aux file) read from
+// r, in order of first citation. Lines are read up to the first unescaped %,
+// so that citations in comments are ignored.
+func CitedKeys(r io.Reader) ([]string, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var src strings.Builder
+	for _, line := range strings.Split(string(b), "\n") {
+		src.WriteString(stripTeXComment(line))
+		src.WriteByte('\n')
+	}
+	var keys []string
+	seen := make(map[string]bool)
+	for _, m := range citeCommand.FindAllStringSubmatch(src.String(), -1) {
+		for _, key := range strings.Split(m[1], ",") {
+			if key = strings.TrimSpace(key); key != "" && !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
+
+// stripTeXComment returns line up to its first unescaped %.
+func stripTeXComment(line string) string {
+	escaped := false
+	for i, r := range line {
+		if r == '%' && !escaped {
+			return line[:i]
+		}
+		escaped = r == '\\' && !escaped
+	}
+	return line
+}
+
+// UnusedKeys returns the cite names of the entries that are not in citedKeys
+// (compared case-insensitively), in order of the entries. If citedKeys
+// contains "*" (as in \nocite{*}), all entries are used.
+func (bib *BibTex) UnusedKeys(citedKeys []string) []string {
+	cited := make(map[string]bool, len(citedKeys))
+	for _, key := range citedKeys {
+		if key == "*" {
+			return nil
+		}
+		cited[citeKey(key)] = true
+	}
+	var unused []string
+	for _, entry := range bib.Entries {
+		if !cited[citeKey(entry.CiteName)] {
+			unused = append(unused, entry.CiteName)
+		}
+	}
+	return unused
+}
+
+// MissingKeys returns the keys in citedKeys without an entry (compared
+// case-insensitively), in order of citedKeys and without repetitions. The key
+// "*" (as in \nocite{*}) is ignored.
+func (bib *BibTex) MissingKeys(citedKeys []string) []string {
+	seen := make(map[string]bool, len(bib.Entries)+len(citedKeys))
+	for _, entry := range bib.Entries {
+		seen[citeKey(entry.CiteName)] = true
+	}
+	var missing []string
+	for _, key := range citedKeys {
+		if key == "*" || seen[citeKey(key)] {
+			continue
+		}
+		seen[citeKey(key)] = true
+		missing = append(missing, key)
+	}
+	return missing
+}
@@ -0,0 +1,181 @@
+package bibtex
+
+import (
+	"strings"
+)
+
+// RemoveEmptyFields removes the fields of the entry whose displayed value is
+// empty or only whitespace, and returns the number of fields removed. String
+// variables are resolved, so a field referring to an empty variable is also
+// removed.
+func (entry *BibEntry) RemoveEmptyFields() int {
+	removed := 0
+	for name, val := range entry.Fields {
+		if strings.TrimSpace(val.String()) == "" {
+			delete(entry.Fields, name)
+			removed++
+		}
+	}
+	return removed
+}
+
+// RemoveEmptyFields removes empty and whitespace-only fields from all entries,
+// and returns the total number of fields removed.
+func (bib *BibTex) RemoveEmptyFields() int {
+	removed := 0
+	for _, entry := range bib.Entries {
+		removed += entry.RemoveEmptyFields()
+	}
+	return removed
+}
+
+// DefaultTypeAliases maps common synonyms of entry types to their canonical
+// type.
+var DefaultTypeAliases = map[string]string{
+	"conference": "inproceedings",
+	"electronic": "online",
+	"www":        "online",
+	"webpage":    "online",
+	"website":    "online",
+}
+
+// KnownTypes are the entry types of BibTeX and biblatex.
+var KnownTypes = []string{
+	"article", "book", "booklet", "conference", "inbook", "incollection",
+	"inproceedings", "manual", "mastersthesis", "misc", "phdthesis",
+	"proceedings", "techreport", "unpublished",
+	// biblatex
+	"bookinbook", "collection", "dataset", "mvbook", "mvcollection",
+	"mvproceedings", "mvreference", "online", "patent", "periodical",
+	"reference", "report", "set", "software", "suppbook", "suppcollection",
+	"suppperiodical", "thesis", "xdata",
+}
+
+// NormalizeTypes rewrites the type of every entry using aliases, which maps
+// (lowercase) entry types to their canonical type. If aliases is nil,
+// DefaultTypeAliases is used. Types without an alias are left unchanged.
+func (bib *BibTex) NormalizeTypes(aliases map[string]string) {
+	if aliases == nil {
+		aliases = DefaultTypeAliases
+	}
+	for _, entry := range bib.Entries {
+		if canonical, ok := aliases[strings.ToLower(entry.Type)]; ok {
+			entry.Type = canonical
+		}
+	}
+}
+
+// UnknownTypes returns the distinct entry types used in bib that are not in
+// KnownTypes, in order of first appearance.
+func (bib *BibTex) UnknownTypes() []string {
+	known := make(map[string]bool, len(KnownTypes))
+	for _, t := range KnownTypes {
+		known[t] = true
+	}
+	var unknown []string
+	for _, entry := range bib.Entries {
+		if !known[entry.Type] {
+			known[entry.Type] = true // Report only once.
+			unknown = append(unknown, entry.Type)
+		}
+	}
+	return unknown
+}
+
+// NormalizePageRange rewrites the page ranges in the pages field to use "--"
+// as separator, e.g. "12-15" or "12–15" becomes "12--15". Lists of ranges
+// separated by commas are normalised range by range. It returns true if the
+// field was changed.
+func (entry *BibEntry) NormalizePageRange() bool {
+	pages, ok := entry.Fields["pages"]
+	if !ok {
+		return false
+	}
+	ranges := strings.Split(pages.String(), ",")
+	changed := false
+	for i, r := range ranges {
+		first, sep, last := splitPageRange(r)
+		if sep == "" || sep == "--" {
+			continue
+		}
+		ranges[i] = first + "--" + last
+		if strings.HasPrefix(r, " ") {
+			ranges[i] = " " + ranges[i]
+		}
+		changed = true
+	}
+	if changed {
+		entry.AddField("pages", NewBibConst(strings.Join(ranges, ",")))
+	}
+	return changed
+}
+
+// VerbatimFields are the (lowercase) names of the fields whose values are
+// identifiers or paths rather than text, which cleanup transforms such as
+// NormalizeWhitespace and NormalizePunctuation leave unchanged. Fields can be
+// added, e.g. VerbatimFields["pdf"] = true.
+var VerbatimFields = map[string]bool{"url": true, "doi": true, "eprint": true, "file": true}
+
+// isVerbatimField returns true if name is in VerbatimFields (compared
+// case-insensitively).
+func isVerbatimField(name string) bool {
+	return VerbatimFields[strings.ToLower(name)]
+}
+
+// PunctuationReplacements maps typographic characters, as pasted from word
+// processors, to their LaTeX input for NormalizePunctuation.
+var PunctuationReplacements = map[rune]string{
+	'“':      "``",
+	'”':      "''",
+	'„':      ",,",
+	'‘':      "`",
+	'’':      "'",
+	'—':      "---",
+	'–':      "--",
+	'\u00a0': "~", // No-break space.
+}
+
+// NormalizePunctuation replaces the characters in PunctuationReplacements
+// (curly quotes, dashes and no-break spaces) in the field values of all
+// entries with their LaTeX input, e.g.
+//
+//	“Go” becomes ``Go''
+//
+// Verbatim fields such as url and doi are left unchanged, as are the values
+// of string variables.
+func (bib *BibTex) NormalizePunctuation() {
+	replace := func(s string) string {
+		var buf strings.Builder
+		for _, r := range s {
+			if repl, ok := PunctuationReplacements[r]; ok {
+				buf.WriteString(repl)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+		return buf.String()
+	}
+	for _, entry := range bib.Entries {
+		for name, val := range entry.Fields {
+			if !isVerbatimField(name) {
+				entry.Fields[name] = mapConstants(val, replace)
+			}
+		}
+	}
+}
+
+// mapConstants returns s with fn applied to each of its constants. Variables
+// are kept as references.
+func mapConstants(s BibString, fn func(string) string) BibString {
+	switch s := s.(type) {
+	case BibConst:
+		return BibConst(fn(string(s)))
+	case *BibComposite:
+		mapped := make([]BibString, len(s.parts))
+		for i, part := range s.parts {
+			mapped[i] = mapConstants(part, fn)
+		}
+		return &BibComposite{parts: mapped}
+	}
+	return s
+}
@@ -62,7 +62,7 @@ func main() {
 		}
 		filter(parsed, &conf)
 	}
-	fmt.Fprintf(writer, parsed.PrettyString())
+	fmt.Fprint(writer, parsed.PrettyString())
 }
 
 func filter(bib *bibtex.BibTex, conf *Config) {
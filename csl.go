@@ -0,0 +1,184 @@
+package bibtex
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// cslTypes maps (lowercase) BibTeX and biblatex entry types to CSL item types.
+// Other types are exported as "document".
+var cslTypes = map[string]string{
+	"article":       "article-journal",
+	"book":          "book",
+	"booklet":       "pamphlet",
+	"conference":    "paper-conference",
+	"dataset":       "dataset",
+	"inbook":        "chapter",
+	"incollection":  "chapter",
+	"inproceedings": "paper-conference",
+	"manual":        "report",
+	"mastersthesis": "thesis",
+	"online":        "webpage",
+	"patent":        "patent",
+	"phdthesis":     "thesis",
+	"proceedings":   "book",
+	"report":        "report",
+	"software":      "software",
+	"techreport":    "report",
+	"thesis":        "thesis",
+	"unpublished":   "manuscript",
+}
+
+// cslGenres are the CSL genres of entry types that CSL does not distinguish.
+var cslGenres = map[string]string{
+	"mastersthesis": "Master's thesis",
+	"phdthesis":     "PhD thesis",
+}
+
+// cslFields maps (lowercase) field names to CSL variables. The number field
+// is the issue of an article and the number of other items.
+var cslFields = map[string]string{
+	"abstract":  "abstract",
+	"address":   "publisher-place",
+	"booktitle": "container-title",
+	"chapter":   "chapter-number",
+	"doi":       "DOI",
+	"edition":   "edition",
+	"isbn":      "ISBN",
+	"issn":      "ISSN",
+	"journal":   "container-title",
+	"keywords":  "keyword",
+	"language":  "language",
+	"note":      "note",
+	"pages":     "page",
+	"series":    "collection-title",
+	"title":     "title",
+	"url":       "URL",
+	"volume":    "volume",
+}
+
+// cslPublisherFields are the fields that name the publisher, in order of
+// preference.
+var cslPublisherFields = []string{"publisher", "school", "institution", "organization"}
+
+// cslNameFields map the name fields to CSL name variables.
+var cslNameFields = map[string]string{"author": "author", "editor": "editor"}
+
+// monthNames are the full names of the months, whose first three letters
+// are the standard BibTeX month abbreviations.
+var monthNames = []string{
+	"january", "february", "march", "april", "may", "june", "july",
+	"august", "september", "october", "november", "december",
+}
+
+// parseMonth returns the number of the month s, e.g. "3", "mar" or "March",
+// or 0 if s is not a month.
+func parseMonth(s string) int {
+	s = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(s), "."))
+	if m, err := strconv.Atoi(s); err == nil && m >= 1 && m <= 12 {
+		return m
+	}
+	for i, name := range monthNames {
+		if len(s) >= 3 && strings.HasPrefix(name, s) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// CSLItem returns the entry as a CSL-JSON item (as used by citeproc
+// processors), ready to be encoded with encoding/json. Values other than
+// VerbatimFields are converted from LaTeX to Unicode text, names are split
+// into family and given names, and the year and month are encoded as the
+// date-parts of the issued date. It returns an error wrapping ErrInvalidName
+// or ErrInvalidField if a name field cannot be parsed or the year is not
+// numeric.
+func (entry *BibEntry) CSLItem() (map[string]interface{}, error) {
+	t := strings.ToLower(entry.Type)
+	item := map[string]interface{}{"id": entry.CiteName, "type": "document"}
+	if cslType, ok := cslTypes[t]; ok {
+		item["type"] = cslType
+	}
+	if genre, ok := cslGenres[t]; ok {
+		item["genre"] = genre
+	}
+	text := func(name string) string {
+		val, ok := entry.field(name)
+		if !ok {
+			return ""
+		}
+		if isVerbatimField(name) {
+			return strings.TrimSpace(inlineString(val))
+		}
+		return strings.TrimSpace(LaTeXToUnicode(inlineString(val)))
+	}
+	for _, name := range entry.FieldNames() {
+		if v, ok := cslFields[strings.ToLower(name)]; ok {
+			if s := text(name); s != "" {
+				item[v] = s
+			}
+		}
+	}
+	if page, ok := item["page"].(string); ok {
+		item["page"] = strings.ReplaceAll(page, "–", "-")
+	}
+	if number := text("number"); number != "" {
+		if t == "article" {
+			item["issue"] = number
+		} else {
+			item["number"] = number
+		}
+	}
+	for _, name := range cslPublisherFields {
+		if s := text(name); s != "" {
+			item["publisher"] = s
+			break
+		}
+	}
+	for field, v := range cslNameFields {
+		val, ok := entry.field(field)
+		if !ok {
+			continue
+		}
+		authors, err := ParseAuthors(inlineString(val))
+		if err != nil {
+			return nil, fmt.Errorf("%s in %s: %w", field, entry.CiteName, err)
+		}
+		if names := cslNames(authors); len(names) > 0 {
+			item[v] = names
+		}
+	}
+	if year := text("year"); year != "" {
+		y, err := strconv.Atoi(year)
+		if err != nil {
+			return nil, fmt.Errorf("%w: year in %s: %v", ErrInvalidField, entry.CiteName, err)
+		}
+		date := []int{y}
+		if m := parseMonth(text("month")); m != 0 {
+			date = append(date, m)
+		}
+		item["issued"] = map[string]interface{}{"date-parts": [][]int{date}}
+	}
+	return item, nil
+}
+
+// cslNames converts authors to CSL names. The "others" placeholder is left
+// out, and names without first names (e.g. organisations) have only a family
+// name.
+func cslNames(authors []Author) []map[string]string {
+	var names []map[string]string
+	for _, a := range authors {
+		if a.IsOthers() {
+			continue
+		}
+		name := map[string]string{"family": LaTeXToUnicode(a.Last)}
+		for k, v := range map[string]string{"given": a.First, "non-dropping-particle": a.Von, "suffix": a.Jr} {
+			if v != "" {
+				name[k] = LaTeXToUnicode(v)
+			}
+		}
+		names = append(names, name)
+	}
+	return names
+}
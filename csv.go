@@ -0,0 +1,112 @@
+package bibtex
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// ToCSV returns the entries as CSV, with one row per entry. The header row
+// has the columns "type" and "key" followed by fields. Fields an entry does not
+// have are left empty, and string variables are resolved.
+func (bib *BibTex) ToCSV(fields []string) (string, error) {
+	var buf strings.Builder
+	w := csv.NewWriter(&buf)
+	if err := w.Write(append([]string{"type", "key"}, fields...)); err != nil {
+		return "", err
+	}
+	for _, entry := range bib.Entries {
+		row := make([]string, 0, len(fields)+2)
+		row = append(row, entry.Type, entry.CiteName)
+		for _, field := range fields {
+			val := ""
+			if v, ok := entry.Fields[field]; ok {
+				val = v.String()
+			}
+			row = append(row, val)
+		}
+		if err := w.Write(row); err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// FromCSV reads entries from CSV with a header row, one entry per row. mapping
+// maps column headers (without surrounding whitespace or a byte order mark)
+// to field names, e.g. {"Title": "title"}, and columns not in mapping are
+// ignored. If mapping is nil, the headers are used as field names. Columns
+// mapped to "type" and "key" set the entry type (misc by default) and cite
+// name, so FromCSV(r, nil) reads the output of ToCSV. Rows without a cite name
+// get one generated from the first author and year. Empty cells are skipped,
+// and columns mapped to the same field are joined (with "and" for authors and
+// editors).
+func FromCSV(r io.Reader, mapping map[string]string) (*BibTex, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	bib := NewBibTex()
+	if err == io.EOF {
+		return bib, nil
+	} else if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(header)) // Field name of each column.
+	for i, column := range header {
+		if i == 0 {
+			column = strings.TrimPrefix(column, "\ufeff") // Byte order mark, e.g. from Excel.
+		}
+		if column = strings.TrimSpace(column); mapping == nil {
+			names[i] = column
+		} else {
+			names[i] = mapping[column]
+		}
+	}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		entryType, key := "misc", ""
+		fields := make(map[string][]string)
+		var order []string
+		for i, cell := range row {
+			if i >= len(names) || names[i] == "" {
+				continue
+			}
+			if cell = strings.TrimSpace(cell); cell == "" {
+				continue
+			}
+			switch names[i] {
+			case "type":
+				entryType = cell
+			case "key":
+				key = cell
+			default:
+				if _, seen := fields[names[i]]; !seen {
+					order = append(order, names[i])
+				}
+				fields[names[i]] = append(fields[names[i]], cell)
+			}
+		}
+		if key == "" {
+			key = uniqueKey(endNoteKey(fields), bib)
+		}
+		entry := NewBibEntry(entryType, key)
+		for _, name := range order {
+			sep, ok := endNoteJoin[name]
+			if !ok {
+				sep = " "
+			}
+			entry.AddField(name, NewBibConst(strings.Join(fields[name], sep)))
+		}
+		bib.AddEntry(entry)
+	}
+	return bib, nil
+}
@@ -0,0 +1,141 @@
+package bibtex
+
+import (
+	"sort"
+	"strings"
+)
+
+// FieldDiff is the difference between the fields of two entries.
+type FieldDiff struct {
+	Added    map[string]BibString    // Fields only in the new entry.
+	Removed  map[string]BibString    // Fields only in the old entry.
+	Modified map[string][2]BibString // Fields with different values: old, new.
+}
+
+// Empty returns true if the entries have the same fields.
+func (d FieldDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// FieldDiff returns the changes to the fields of entry in other. Field names
+// are matched case-insensitively (and reported as in other for added fields,
+// as in entry otherwise), and values are compared by their resolved strings
+// (see BibStringEqual).
+func (entry *BibEntry) FieldDiff(other *BibEntry) FieldDiff {
+	d := FieldDiff{
+		Added:    make(map[string]BibString),
+		Removed:  make(map[string]BibString),
+		Modified: make(map[string][2]BibString),
+	}
+	for name, val := range entry.Fields {
+		newVal, ok := other.field(name)
+		switch {
+		case !ok:
+			d.Removed[name] = val
+		case !BibStringEqual(val, newVal):
+			d.Modified[name] = [2]BibString{val, newVal}
+		}
+	}
+	for name, val := range other.Fields {
+		if _, ok := entry.field(name); !ok {
+			d.Added[name] = val
+		}
+	}
+	return d
+}
+
+// DiffString returns the changes to the entries of bib in other as text
+// similar to a unified diff, or an empty string if there are none, e.g.
+//
+//	-@misc{old}
+//	-  title = {Old}
+//	 @article{knuth}
+//	-  year = {1984}
+//	+  year = {1986}
+//	+@book{new}
+//
+// Entries are matched by cite name (case-insensitively) and listed in the
+// order of bib followed by the entries added in other. Fields are listed in
+// alphabetical order (case-insensitively), values with string variables
+// resolved, so the output is deterministic, e.g. for golden tests.
+func (bib *BibTex) DiffString(other *BibTex) string {
+	var buf strings.Builder
+	writeEntry := func(prefix string, entry *BibEntry) {
+		buf.WriteString(prefix + "@" + entry.Type + "{" + entry.CiteName + "}\n")
+		for _, name := range sortFieldNames(entry.FieldNames()) {
+			writeDiffField(&buf, prefix, name, entry.Fields[name])
+		}
+	}
+	oldEntries, newEntries := entriesByKey(bib), entriesByKey(other)
+	for _, entry := range bib.Entries {
+		newEntry := newEntries[citeKey(entry.CiteName)]
+		if newEntry == nil {
+			writeEntry("-", entry)
+			continue
+		}
+		d := entry.FieldDiff(newEntry)
+		if d.Empty() && entry.Type == newEntry.Type {
+			continue
+		}
+		if entry.Type == newEntry.Type {
+			buf.WriteString(" @" + entry.Type + "{" + entry.CiteName + "}\n")
+		} else {
+			buf.WriteString("-@" + entry.Type + "{" + entry.CiteName + "}\n")
+			buf.WriteString("+@" + newEntry.Type + "{" + newEntry.CiteName + "}\n")
+		}
+		var names []string
+		for _, fields := range []map[string]BibString{d.Added, d.Removed} {
+			for name := range fields {
+				names = append(names, name)
+			}
+		}
+		for name := range d.Modified {
+			names = append(names, name)
+		}
+		for _, name := range sortFieldNames(names) {
+			if val, ok := d.Removed[name]; ok {
+				writeDiffField(&buf, "-", name, val)
+			} else if val, ok := d.Added[name]; ok {
+				writeDiffField(&buf, "+", name, val)
+			} else {
+				writeDiffField(&buf, "-", name, d.Modified[name][0])
+				writeDiffField(&buf, "+", name, d.Modified[name][1])
+			}
+		}
+	}
+	for _, entry := range other.Entries {
+		if oldEntries[citeKey(entry.CiteName)] == nil {
+			writeEntry("+", entry)
+		}
+	}
+	return buf.String()
+}
+
+// entriesByKey returns the first entry of bib with each cite key.
+func entriesByKey(bib *BibTex) map[string]*BibEntry {
+	entries := make(map[string]*BibEntry, len(bib.Entries))
+	for _, entry := range bib.Entries {
+		if _, exists := entries[citeKey(entry.CiteName)]; !exists {
+			entries[citeKey(entry.CiteName)] = entry
+		}
+	}
+	return entries
+}
+
+// sortFieldNames sorts names alphabetically, case-insensitively, and returns
+// them.
+func sortFieldNames(names []string) []string {
+	sort.Slice(names, func(i, j int) bool {
+		if a, b := strings.ToLower(names[i]), strings.ToLower(names[j]); a != b {
+			return a < b
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// writeDiffField writes a line of DiffString for the field name with value
+// val.
+func writeDiffField(buf *strings.Builder, prefix, name string, val BibString) {
+	buf.WriteString(prefix + "  " + name + " = {" + inlineString(val) + "}\n")
+}
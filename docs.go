@@ -16,6 +16,29 @@
 // where BIBTYPE is the type of document (e.g. inproceedings, article, etc.)
 // and IDENT is a string identifier.
 //
+// Cite keys
+//
+// Cite keys (and entry types, field names and string variables) may contain
+// letters (including non-ASCII letters), digits and the symbols
+//
+//     ! $ & * + - . / : ; < > ? [ ] ^ _ ` | ~
+//
+// e.g. Knuth:1984, 10.1000/xyz or foo-bar_baz. Whitespace and the characters
+// " # % ' ( ) , = { } @ \ are not allowed, and an entry with such a cite key
+// fails to parse with an error wrapping ErrInvalidCiteKey.
+//
+// String variables
+//
+// String variables are defined with @string{name = value} and may be used in
+// field values (and in other @string values) before or after their definition.
+// They are resolved in two passes: a variable that is already defined when it
+// is used refers to the definition in effect at that point, and a variable that
+// is used before any definition refers to the last definition in the input
+// once parsing has finished. A variable that is never defined is an error
+// wrapping ErrUnknownStringVar (unless ParseOptions.AllowUndefinedStringVars is
+// set), and variables defined in terms of each other are an error wrapping
+// ErrStringVarCycle. Both are reported as a ParseError at the reference.
+//
 // The bibtex format is not standardised, this parser follows the descriptions
 // found in the link below. If there are any problems, please file any issues
 // with a minimal working example at the GitHub repository.
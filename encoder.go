@@ -0,0 +1,331 @@
+package bibtex
+
+import (
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// QuoteStyle is the delimiter used for string values in the output.
+type QuoteStyle int
+
+const (
+	// BraceQuotes writes values in braces, e.g. {Title}.
+	BraceQuotes QuoteStyle = iota
+	// DoubleQuotes writes values in double quotes, e.g. "Title", unless the
+	// value contains a double quote or braces, which is written in braces.
+	DoubleQuotes
+)
+
+// Encoder writes BibTeX to an output stream. The output is configured with
+// chainable setters, e.g.
+//
+//	bibtex.NewEncoder(w).Indent("\t").TrailingComma(true).Encode(bib)
+//
+// String, RawString and PrettyString of BibTex are presets of an Encoder.
+type Encoder struct {
+	w io.Writer
+
+	indent        string
+	quoteStyle    QuoteStyle
+	fieldOrder    []string
+	expandStrings bool
+	selfContained bool
+	omitPreambles bool
+	trailingComma bool
+	alignFields   bool
+	latexEscape   bool
+	preserveCase  bool
+}
+
+// NewEncoder returns a new encoder that writes to w. By default the output is
+// the internal representation (as RawString), including comments, string
+// variables and preambles.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, indent: "  "}
+}
+
+// Indent sets the indentation of fields. Default two spaces.
+func (e *Encoder) Indent(indent string) *Encoder {
+	e.indent = indent
+	return e
+}
+
+// QuoteStyle sets the delimiters of string values. Default BraceQuotes.
+func (e *Encoder) QuoteStyle(style QuoteStyle) *Encoder {
+	e.quoteStyle = style
+	return e
+}
+
+// FieldOrder writes the fields in this order, followed by any other fields in
+// alphabetical order (see DefaultFieldOrder). Default nil, which writes fields
+// in the order they were added to the entry.
+func (e *Encoder) FieldOrder(order []string) *Encoder {
+	e.fieldOrder = order
+	return e
+}
+
+// ExpandStrings writes values with string variables resolved, and omits the
+// @string definitions and @comment entries. Default false, which writes
+// comments verbatim before the entry that followed them in the input, or
+// before the @string definitions if they came before all entries.
+func (e *Encoder) ExpandStrings(expand bool) *Encoder {
+	e.expandStrings = expand
+	return e
+}
+
+// SelfContained writes values with string variables resolved as
+// ExpandStrings, and makes sure the output parses on its own: variables
+// without a value are written as empty strings and unbalanced braces in
+// values are dropped or closed. Default false.
+func (e *Encoder) SelfContained(selfContained bool) *Encoder {
+	e.selfContained = selfContained
+	return e
+}
+
+// OmitPreambles omits @preamble entries from the output. Default false.
+func (e *Encoder) OmitPreambles(omit bool) *Encoder {
+	e.omitPreambles = omit
+	return e
+}
+
+// TrailingComma writes a comma after the last field of each entry.
+// Default false.
+func (e *Encoder) TrailingComma(trailing bool) *Encoder {
+	e.trailingComma = trailing
+	return e
+}
+
+// AlignFields pads the field names of each entry so that the values line up.
+// Default false.
+func (e *Encoder) AlignFields(align bool) *Encoder {
+	e.alignFields = align
+	return e
+}
+
+// LaTeXEscape escapes the LaTeX special characters & % $ # _ in string values
+// with a backslash, unless they are escaped already. Default false.
+func (e *Encoder) LaTeXEscape(escape bool) *Encoder {
+	e.latexEscape = escape
+	return e
+}
+
+// PreserveTypeCase writes the type of each entry as it was written in the
+// input, e.g. @Article or @INPROCEEDINGS (see BibEntry.TypeSpelling), instead
+// of in lower case. Default false.
+func (e *Encoder) PreserveTypeCase(preserve bool) *Encoder {
+	e.preserveCase = preserve
+	return e
+}
+
+// Encode writes bib to the output stream.
+func (e *Encoder) Encode(bib *BibTex) error {
+	_, err := io.WriteString(e.w, e.encodeString(bib))
+	return err
+}
+
+// encodeString returns bib encoded as a string.
+func (e *Encoder) encodeString(bib *BibTex) string {
+	var bibtex strings.Builder
+	e.write(&bibtex, bib)
+	return bibtex.String()
+}
+
+// write writes the comments, string variables, preambles and entries of bib.
+func (e *Encoder) write(bibtex *strings.Builder, bib *BibTex) {
+	bibtex.Grow(len(bib.Entries) * entrySizeHint)
+	comments := 0 // Number of comments written.
+	writeComments := func(entries int) {
+		if e.expandStrings || e.selfContained {
+			return
+		}
+		for ; comments < len(bib.Comments) && bib.commentPosition(comments) <= entries; comments++ {
+			writeComment(bibtex, bib.Comments[comments])
+		}
+	}
+	writeComments(0)
+	if !e.expandStrings && !e.selfContained {
+		for _, k := range bib.StringVarKeys() {
+			bibtex.WriteString("@string{")
+			bibtex.WriteString(k)
+			bibtex.WriteString(" = ")
+			bibtex.WriteString(e.quote(inlineString(bib.StringVar[k])))
+			bibtex.WriteString("}\n")
+		}
+	}
+	if !e.omitPreambles {
+		for _, preamble := range bib.Preambles {
+			bibtex.WriteString("@preamble{")
+			bibtex.WriteString(e.format(preamble))
+			bibtex.WriteString("}\n")
+		}
+	}
+	for i, entry := range bib.Entries {
+		writeComments(i)
+		e.writeEntry(bibtex, entry)
+	}
+	writeComments(math.MaxInt)
+}
+
+// writeComment writes a @comment entry with content c, in braces unless c has
+// unbalanced braces (and was delimited by parentheses in the input).
+func writeComment(bibtex *strings.Builder, c string) {
+	if balanceBraces(c) == c {
+		bibtex.WriteString("@comment{" + c + "}\n")
+	} else {
+		bibtex.WriteString("@comment(" + c + ")\n")
+	}
+}
+
+// format formats val as a quoted string, resolved or in its internal
+// representation depending on the options.
+func (e *Encoder) format(val BibString) string {
+	switch {
+	case e.selfContained:
+		return e.quote(balanceBraces(strings.TrimSpace(inlineString(val))))
+	case e.expandStrings:
+		return e.quote(strings.TrimSpace(inlineString(val)))
+	}
+	return e.raw(val)
+}
+
+// raw formats val in its internal representation (as BibString.RawString),
+// with constants quoted according to the options.
+func (e *Encoder) raw(val BibString) string {
+	switch val := val.(type) {
+	case nil:
+		return e.quote("")
+	case BibConst:
+		return e.quote(string(val))
+	case *BibVar:
+		return val.Key
+	case *BibComposite:
+		parts := val.flatten()
+		raw := make([]string, len(parts))
+		for i, part := range parts {
+			raw[i] = e.raw(part)
+		}
+		return strings.Join(raw, " # ")
+	default:
+		return val.RawString()
+	}
+}
+
+// quote escapes s if LaTeXEscape is set, and puts it in braces or double
+// quotes according to QuoteStyle.
+func (e *Encoder) quote(s string) string {
+	if e.latexEscape {
+		s = latexEscape(s)
+	}
+	if e.quoteStyle == DoubleQuotes && !strings.ContainsAny(s, "\"{}") {
+		return "\"" + s + "\""
+	}
+	return "{" + s + "}"
+}
+
+// latexEscape puts a backslash before each unescaped & % $ # _ in s.
+func latexEscape(s string) string {
+	var buf strings.Builder
+	escaped := false
+	for _, r := range s {
+		if strings.ContainsRune("&%$#_", r) && !escaped {
+			buf.WriteRune('\\')
+		}
+		escaped = r == '\\' && !escaped
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+// inlineString returns the displayed string of val, with variables without a
+// value resolved to an empty string.
+func inlineString(val BibString) string {
+	switch val := val.(type) {
+	case nil:
+		return ""
+	case *BibVar:
+		return inlineString(val.Value)
+	case *BibComposite:
+		var buf strings.Builder
+		for _, s := range val.parts {
+			buf.WriteString(inlineString(s))
+		}
+		return buf.String()
+	default:
+		return val.String()
+	}
+}
+
+// balanceBraces removes closing braces without a matching opening brace from
+// s, and closes braces left open at the end.
+func balanceBraces(s string) string {
+	var buf strings.Builder
+	depth := 0
+	for _, r := range s {
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			depth--
+		}
+		buf.WriteRune(r)
+	}
+	buf.WriteString(strings.Repeat("}", depth))
+	return buf.String()
+}
+
+// writeEntry writes entry to bibtex with one field per line (in the order of
+// the entry or FieldOrder), followed by a comma except after the last field
+// (unless TrailingComma is set). An entry without fields is written as
+// "@type{key,\n}". Numeric values are written bare.
+func (e *Encoder) writeEntry(bibtex *strings.Builder, entry *BibEntry) {
+	bibtex.WriteString("@")
+	if e.preserveCase {
+		bibtex.WriteString(entry.TypeSpelling())
+	} else {
+		bibtex.WriteString(entry.Type)
+	}
+	bibtex.WriteString("{")
+	bibtex.WriteString(entry.CiteName)
+	bibtex.WriteString(",\n")
+	keys := entry.OrderedFieldNames()
+	if e.fieldOrder != nil {
+		keys = canonicalFieldOrder(entry, e.fieldOrder)
+	}
+	keylen := 0
+	if e.alignFields {
+		for _, key := range keys {
+			if len(key) > keylen {
+				keylen = len(key)
+			}
+		}
+	}
+	for i, key := range keys {
+		val := entry.Fields[key]
+		if i > 0 {
+			bibtex.WriteString(",\n")
+		}
+		bibtex.WriteString(e.indent)
+		bibtex.WriteString(key)
+		if len(key) < keylen {
+			bibtex.WriteString(strings.Repeat(" ", keylen-len(key)))
+		}
+		bibtex.WriteString(" = ")
+		if i, err := strconv.Atoi(strings.TrimSpace(inlineString(val))); err == nil {
+			bibtex.WriteString(strconv.Itoa(i))
+		} else {
+			bibtex.WriteString(e.format(val))
+		}
+	}
+	if len(entry.Fields) > 0 {
+		if e.trailingComma {
+			bibtex.WriteString(",")
+		}
+		bibtex.WriteString("\n")
+	}
+	bibtex.WriteString("}\n")
+}
@@ -0,0 +1,194 @@
+package bibtex
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// endNoteTypes maps EndNote reference types (%0) to BibTeX entry types.
+var endNoteTypes = map[string]string{
+	"journal article":        "article",
+	"magazine article":       "article",
+	"newspaper article":      "article",
+	"book":                   "book",
+	"edited book":            "book",
+	"book section":           "incollection",
+	"conference paper":       "inproceedings",
+	"conference proceedings": "proceedings",
+	"thesis":                 "phdthesis",
+	"report":                 "techreport",
+	"manuscript":             "unpublished",
+	"generic":                "misc",
+	"web page":               "misc",
+}
+
+// endNoteFields maps EndNote tags to BibTeX field names. Tags that may appear
+// more than once are joined with endNoteJoin.
+var endNoteFields = map[string]string{
+	"A": "author",
+	"E": "editor",
+	"T": "title",
+	"J": "journal",
+	"B": "booktitle",
+	"D": "year",
+	"8": "month",
+	"V": "volume",
+	"N": "number",
+	"P": "pages",
+	"I": "publisher",
+	"C": "address",
+	"S": "series",
+	"7": "edition",
+	"K": "keywords",
+	"X": "abstract",
+	"U": "url",
+	"R": "doi",
+	"@": "isbn",
+	"Z": "note",
+	"F": "key",
+}
+
+// endNoteJoin are the separators for repeated EndNote tags.
+var endNoteJoin = map[string]string{
+	"author":   " and ",
+	"editor":   " and ",
+	"keywords": ", ",
+}
+
+// ParseEndNote parses bibliography records in the EndNote tagged format (.enw
+// or .end), where each line of a record is a tag such as %T followed by its
+// value, and records are separated by blank lines.
+//
+// The reference type (%0) is converted to the corresponding BibTeX entry type
+// (misc if unknown) and known tags are converted to BibTeX fields. The label
+// (%F) is used as cite name if present, otherwise one is generated from the
+// first author's last name and the year.
+func ParseEndNote(r io.Reader) (*BibTex, error) {
+	bib := NewBibTex()
+	scanner := bufio.NewScanner(r)
+	var record [][2]string // (tag, value) pairs of the current record.
+	flush := func() {
+		if len(record) > 0 {
+			bib.AddEntry(endNoteEntry(record, bib))
+			record = nil
+		}
+	}
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimRightFunc(scanner.Text(), unicode.IsSpace)
+		switch {
+		case strings.TrimSpace(line) == "":
+			flush()
+		case strings.HasPrefix(line, "%") && len(line) >= 2:
+			tag := line[1:2]
+			record = append(record, [2]string{tag, strings.TrimSpace(line[2:])})
+		case len(record) > 0: // Continuation of the previous tag.
+			record[len(record)-1][1] += " " + strings.TrimSpace(line)
+		default:
+			return nil, fmt.Errorf("%w: line %d: %q", ErrInvalidEndNote, lineno, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return bib, nil
+}
+
+// endNoteEntry converts an EndNote record to an entry with a cite name that is
+// unique in bib.
+func endNoteEntry(record [][2]string, bib *BibTex) *BibEntry {
+	entryType := "misc"
+	fields := make(map[string][]string)
+	var names []string // Field names in order of appearance.
+	for _, tv := range record {
+		tag, val := tv[0], tv[1]
+		if tag == "0" {
+			if t, ok := endNoteTypes[strings.ToLower(val)]; ok {
+				entryType = t
+			}
+			continue
+		}
+		name, ok := endNoteFields[tag]
+		if !ok || val == "" {
+			continue
+		}
+		if _, seen := fields[name]; !seen {
+			names = append(names, name)
+		}
+		fields[name] = append(fields[name], val)
+	}
+	// Conference papers and book sections put the containing title in %B,
+	// but journal articles from some exporters use %B for the journal.
+	if entryType == "article" && fields["journal"] == nil && fields["booktitle"] != nil {
+		fields["journal"], fields["booktitle"] = fields["booktitle"], nil
+		names = append(names, "journal")
+	}
+
+	key := ""
+	if label := fields["key"]; len(label) > 0 {
+		key = label[0]
+	} else {
+		key = endNoteKey(fields)
+	}
+	entry := NewBibEntry(entryType, uniqueKey(key, bib))
+	for _, name := range names {
+		if name == "key" || fields[name] == nil {
+			continue
+		}
+		sep, ok := endNoteJoin[name]
+		if !ok {
+			sep = " "
+		}
+		entry.AddField(name, NewBibConst(strings.Join(fields[name], sep)))
+	}
+	return entry
+}
+
+// endNoteKey generates a cite name from the last name of the first author (or
+// editor) and the year.
+func endNoteKey(fields map[string][]string) string {
+	key := "ref"
+	for _, role := range []string{"author", "editor"} {
+		if names := fields[role]; len(names) > 0 {
+			if author, err := ParseAuthor(names[0]); err == nil {
+				key = strings.Map(func(r rune) rune {
+					if unicode.IsLetter(r) || unicode.IsDigit(r) {
+						return r
+					}
+					return -1
+				}, author.Last)
+				break
+			}
+		}
+	}
+	if year := fields["year"]; len(year) > 0 {
+		key += strings.TrimSpace(year[0])
+	}
+	return key
+}
+
+// uniqueKey returns key, or key suffixed with a letter if an entry with the
+// cite name key already exists in bib.
+func uniqueKey(key string, bib *BibTex) string {
+	exists := func(k string) bool {
+		return bib.EntryByKey(k) != nil
+	}
+	if !exists(key) {
+		return key
+	}
+	for i := 0; ; i++ {
+		suffix := ""
+		for n := i; ; n = n/26 - 1 {
+			suffix = string(rune('a'+n%26)) + suffix
+			if n < 26 {
+				break
+			}
+		}
+		if k := key + suffix; !exists(k) {
+			return k
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package bibtex
+
+import (
+	"sort"
+	"strings"
+)
+
+// Equal returns true if bib and other have the same entries (by type, cite
+// name and field values), the same string variables, preambles and comments,
+// regardless of the order they were added in. Values are compared by their
+// resolved (displayed) strings.
+func (bib *BibTex) Equal(other *BibTex) bool {
+	if bib == nil || other == nil {
+		return bib == other
+	}
+	if len(bib.Entries) != len(other.Entries) ||
+		len(bib.StringVar) != len(other.StringVar) ||
+		len(bib.Preambles) != len(other.Preambles) {
+		return false
+	}
+	for key, v := range bib.StringVar {
+		ov, ok := other.StringVar[key]
+		if !ok || !v.Equal(ov) {
+			return false
+		}
+	}
+	return sameStrings(append([]string(nil), bib.Comments...), append([]string(nil), other.Comments...)) &&
+		sameStrings(preambleStrings(bib), preambleStrings(other)) &&
+		sameStrings(entryStrings(bib), entryStrings(other))
+}
+
+// BibStringEqual returns true if a and b have the same resolved (displayed)
+// string, regardless of their internal representation, e.g. the constant
+// "ACM Press" equals the composite acm # " Press". Variables without a value
+// resolve to an empty string, as does a nil BibString.
+func BibStringEqual(a, b BibString) bool {
+	return inlineString(a) == inlineString(b)
+}
+
+// Equal returns true if c and other are the same constant.
+func (c BibConst) Equal(other BibConst) bool {
+	return c == other
+}
+
+// Equal returns true if v and other have the same key and the same resolved
+// value.
+func (v *BibVar) Equal(other *BibVar) bool {
+	if v == nil || other == nil {
+		return v == other
+	}
+	return v.Key == other.Key && BibStringEqual(v.Value, other.Value)
+}
+
+// Equal returns true if c and other have the same resolved value, even if
+// they are made of different parts.
+func (c *BibComposite) Equal(other *BibComposite) bool {
+	if c == nil || other == nil {
+		return c == other
+	}
+	return BibStringEqual(c, other)
+}
+
+// entryStrings returns a canonical string for each entry of bib.
+func entryStrings(bib *BibTex) []string {
+	strs := make([]string, len(bib.Entries))
+	for i, entry := range bib.Entries {
+		var buf strings.Builder
+		buf.WriteString(entry.Type)
+		buf.WriteByte(0)
+		buf.WriteString(entry.CiteName)
+		for _, name := range entry.FieldNames() {
+			buf.WriteByte(0)
+			buf.WriteString(name)
+			buf.WriteByte(0)
+			buf.WriteString(inlineString(entry.Fields[name]))
+		}
+		strs[i] = buf.String()
+	}
+	return strs
+}
+
+// preambleStrings returns the displayed string of each preamble of bib.
+func preambleStrings(bib *BibTex) []string {
+	strs := make([]string, len(bib.Preambles))
+	for i, preamble := range bib.Preambles {
+		strs[i] = inlineString(preamble)
+	}
+	return strs
+}
+
+// sameStrings returns true if a and b contain the same strings in any order.
+// Both slices are sorted in place.
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -10,9 +10,40 @@ var (
 	ErrUnexpectedAtsign = errors.New("Unexpected @ sign")
 	// ErrUnknownStringVar is an error for looking up undefined string var.
 	ErrUnknownStringVar = errors.New("Unknown string variable")
+	// ErrStringVarCycle is an error for string vars defined in terms of themselves.
+	ErrStringVarCycle = errors.New("Cyclic string variable")
+	// ErrUnterminatedValue is an error for a quoted or braced value without its
+	// closing quote or brace.
+	ErrUnterminatedValue = errors.New("Unterminated value")
+	// ErrUnterminatedEntry is an error for an entry without its closing brace.
+	ErrUnterminatedEntry = errors.New("Unterminated entry")
+	// ErrStringVarRedefined is an error for defining a string var that exists.
+	ErrStringVarRedefined = errors.New("Redefined string variable")
+	// ErrEmptyField is an error for a field with an empty value.
+	ErrEmptyField = errors.New("Empty field")
+	// ErrInvalidName is an error for names that cannot be parsed.
+	ErrInvalidName = errors.New("Invalid name")
+	// ErrMissingField is an error for looking up a field an entry does not have.
+	ErrMissingField = errors.New("Missing field")
+	// ErrInvalidField is an error for a field value that cannot be interpreted.
+	ErrInvalidField = errors.New("Invalid field value")
+	// ErrInvalidAbbreviation is an error for malformed journal abbreviation lists.
+	ErrInvalidAbbreviation = errors.New("Invalid journal abbreviation")
+	// ErrInvalidEndNote is an error for malformed EndNote records.
+	ErrInvalidEndNote = errors.New("Invalid EndNote record")
+	// ErrDuplicateCiteKey is an error for entries with an existing cite name.
+	ErrDuplicateCiteKey = errors.New("Duplicate cite key")
+	// ErrInvalidCiteKey is an error for a cite key with characters not allowed.
+	ErrInvalidCiteKey = errors.New("Invalid cite key")
+	// ErrUnknownCiteKey is an error for a reference to an entry that does not exist.
+	ErrUnknownCiteKey = errors.New("Unknown cite key")
+	// ErrInvalidIdentifier is an error for an ISBN or ISSN with a wrong checksum.
+	ErrInvalidIdentifier = errors.New("Invalid identifier")
 )
 
 // ErrParse is a parse error.
+//
+// Deprecated: The parser reports errors as ParseError.
 type ErrParse struct {
 	Pos TokenPos
 	Err string // Error string returned from parser.
@@ -21,3 +52,22 @@ type ErrParse struct {
 func (e *ErrParse) Error() string {
 	return fmt.Sprintf("Parse failed at %s: %s", e.Pos, e.Err)
 }
+
+// ParseError is a parse error with the position of the token it occurred at.
+// All errors returned by the parser are of this type.
+type ParseError struct {
+	Line    int    // Line of the error (starting from 1).
+	Column  int    // Column of the error (starting from 1).
+	Message string // Error string returned from parser.
+
+	err error // Underlying error, if any.
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("Parse failed at %d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// Unwrap returns the underlying error, e.g. ErrUnknownStringVar.
+func (e ParseError) Unwrap() error {
+	return e.err
+}
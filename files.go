@@ -0,0 +1,65 @@
+package bibtex
+
+import (
+	"fmt"
+	"os"
+)
+
+// ParseFiles parses the bibtex files at paths and merges them into a single
+// BibTex, in the order given.
+//
+// String variables accumulate across files: a @string defined in one file is
+// visible to entries in the files after it, and a later definition of the same
+// variable replaces the earlier one from that point on. Preambles and comments
+// of all files are kept.
+//
+// If an entry has the same cite name (compared case-insensitively) as an entry
+// parsed before it (in the same or an earlier file), the first entry is kept
+// and a non-fatal warning wrapping ErrDuplicateCiteKey is returned. Errors
+// opening or parsing a file are fatal and are annotated with the file name.
+func ParseFiles(paths ...string) (*BibTex, []error, error) {
+	merged := NewBibTex()
+	var warnings []error
+	seen := make(map[string]string) // Cite name to file first defining it.
+	for _, path := range paths {
+		bib, err := parseFile(path, merged)
+		if err != nil {
+			return nil, warnings, err
+		}
+		merged.Preambles = append(merged.Preambles, bib.Preambles...)
+		comments := 0
+		for i, entry := range bib.Entries {
+			for ; comments < len(bib.Comments) && bib.commentPosition(comments) <= i; comments++ {
+				merged.AddComment(bib.Comments[comments])
+			}
+			if first, dup := seen[citeKey(entry.CiteName)]; dup {
+				warnings = append(warnings, fmt.Errorf("%s: %w: %s (first defined in %s)", path, ErrDuplicateCiteKey, entry.CiteName, first))
+				continue
+			}
+			seen[citeKey(entry.CiteName)] = path
+			merged.AddEntry(entry)
+		}
+		for _, c := range bib.Comments[comments:] {
+			merged.AddComment(c)
+		}
+	}
+	return merged, warnings, nil
+}
+
+// parseFile parses the file at path with the string variables of vars
+// predefined. New string variables are added to vars.
+func parseFile(path string, vars *BibTex) (*BibTex, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	l := NewLexer(f)
+	l.bib.StringVar, l.bib.stringVarKeys = vars.StringVar, vars.stringVarKeys
+	bib, err := parse(l)
+	vars.stringVarKeys = l.bib.stringVarKeys
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return bib, nil
+}
@@ -0,0 +1,76 @@
+package bibtex
+
+import (
+	"strings"
+)
+
+// FilterByYear returns a new BibTex with the entries whose year is between min
+// and max (inclusive). Entries without a year or with a non-numeric year are
+// excluded. String variables and preambles are shared with bib.
+func (bib *BibTex) FilterByYear(min, max int) *BibTex {
+	return bib.FilterByYearErrors(min, max, nil)
+}
+
+// FilterByYearErrors is like FilterByYear but reports each entry excluded
+// because of a missing or non-numeric year on errs. Sending to errs blocks, so
+// errs should be drained concurrently or have enough buffer. If errs is nil,
+// the errors are discarded.
+func (bib *BibTex) FilterByYearErrors(min, max int, errs chan<- error) *BibTex {
+	var entries []*BibEntry
+	for _, entry := range bib.Entries {
+		year, err := entry.GetYear()
+		if err != nil {
+			if errs != nil {
+				errs <- err
+			}
+			continue
+		}
+		if min <= year && year <= max {
+			entries = append(entries, entry)
+		}
+	}
+	return bib.withEntries(entries)
+}
+
+// Partition splits the entries of bib into two new BibTex values: matched with
+// the entries for which fn returns true and rest with the others, both in
+// order. String variables and preambles are shared with bib.
+func (bib *BibTex) Partition(fn func(*BibEntry) bool) (matched, rest *BibTex) {
+	var yes, no []*BibEntry
+	for _, entry := range bib.Entries {
+		if fn(entry) {
+			yes = append(yes, entry)
+		} else {
+			no = append(no, entry)
+		}
+	}
+	return bib.withEntries(yes), bib.withEntries(no)
+}
+
+// withEntries returns a new BibTex with the given entries and the string
+// variables, preambles and comments of bib.
+func (bib *BibTex) withEntries(entries []*BibEntry) *BibTex {
+	res := NewBibTex()
+	res.Preambles = append(res.Preambles, bib.Preambles...)
+	res.Comments = append(res.Comments, bib.Comments...)
+	res.commentPos = append(res.commentPos, bib.commentPos...)
+	res.log = bib.log
+	for k, v := range bib.StringVar {
+		res.StringVar[k] = v
+	}
+	res.stringVarKeys = append(res.stringVarKeys, bib.stringVarKeys...)
+	res.Entries = append(res.Entries, entries...)
+	return res
+}
+
+// EntriesOfType returns the entries of type t (compared case-insensitively) in
+// order, or nil if there are none.
+func (bib *BibTex) EntriesOfType(t string) []*BibEntry {
+	var entries []*BibEntry
+	for _, entry := range bib.Entries {
+		if strings.EqualFold(entry.Type, t) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,292 @@
+package bibtex
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+)
+
+// gobString is the serialised form of a BibString.
+type gobString struct {
+	Kind     byte        // One of gobConst, gobVar or gobComposite, zero if nil.
+	Const    string      // Value of a BibConst.
+	Key      string      // Key of a BibVar.
+	Value    *gobString  // Value of a BibVar.
+	Shadowed bool        // BibVar is an earlier definition of a redefined variable.
+	Parts    []gobString // Elements of a BibComposite.
+}
+
+const (
+	gobConst byte = iota + 1
+	gobVar
+	gobComposite
+)
+
+// gobField is a field of an entry.
+type gobField struct {
+	Name  string
+	Value gobString
+}
+
+// gobEntry is the serialised form of a BibEntry, with fields in order.
+type gobEntry struct {
+	Type     string
+	CiteName string
+	Fields   []gobField
+}
+
+// gobBibTex is the serialised form of a BibTex, with string variables in order
+// of definition.
+type gobBibTex struct {
+	Preambles  []gobString
+	Entries    []gobEntry
+	StringVars []gobString
+	Comments   []string
+	CommentPos []int // Number of entries before each comment.
+}
+
+// toGobString converts s to its serialised form. BibString implementations
+// other than those in this package are stored as constants. Variables of bib
+// that are earlier definitions of a redefined variable are marked as shadowed,
+// so they are not bound to the last definition when decoded; bib may be nil.
+// It returns an error wrapping ErrStringVarCycle if a variable refers to
+// itself.
+func toGobString(s BibString, bib *BibTex) (gobString, error) {
+	return toGobStringSeen(s, bib, map[*BibVar]bool{})
+}
+
+// toGobStringSeen converts s like toGobString, where seen are the variables
+// whose values are being converted.
+func toGobStringSeen(s BibString, bib *BibTex, seen map[*BibVar]bool) (gobString, error) {
+	switch s := s.(type) {
+	case nil:
+		return gobString{}, nil
+	case BibConst:
+		return gobString{Kind: gobConst, Const: string(s)}, nil
+	case *BibVar:
+		if seen[s] {
+			return gobString{}, fmt.Errorf("%w: %s", ErrStringVarCycle, s.Key)
+		}
+		g := gobString{Kind: gobVar, Key: s.Key, Shadowed: bib != nil && !bib.refersByKey(s)}
+		if s.Value != nil {
+			seen[s] = true
+			defer delete(seen, s)
+			value, err := toGobStringSeen(s.Value, bib, seen)
+			if err != nil {
+				return gobString{}, err
+			}
+			g.Value = &value
+		}
+		return g, nil
+	case *BibComposite:
+		g := gobString{Kind: gobComposite, Parts: make([]gobString, len(s.parts))}
+		for i, part := range s.parts {
+			var err error
+			if g.Parts[i], err = toGobStringSeen(part, bib, seen); err != nil {
+				return gobString{}, err
+			}
+		}
+		return g, nil
+	default:
+		return gobString{Kind: gobConst, Const: s.String()}, nil
+	}
+}
+
+// fromGobString converts g back to a BibString. Variables defined in vars are
+// shared unless shadowed, other variables are created with their serialised
+// value.
+func fromGobString(g gobString, vars map[string]*BibVar) BibString {
+	switch g.Kind {
+	case gobConst:
+		return BibConst(g.Const)
+	case gobVar:
+		if v, ok := vars[g.Key]; ok && !g.Shadowed {
+			return v
+		}
+		return newGobVar(g, vars)
+	case gobComposite:
+		parts := make([]BibString, len(g.Parts))
+		for i, part := range g.Parts {
+			parts[i] = fromGobString(part, vars)
+		}
+		return &BibComposite{parts: parts}
+	}
+	return nil
+}
+
+// newGobVar creates the variable serialised as g.
+func newGobVar(g gobString, vars map[string]*BibVar) *BibVar {
+	v := &BibVar{Key: g.Key}
+	if g.Value != nil {
+		v.Value = fromGobString(*g.Value, vars)
+	}
+	return v
+}
+
+func toGobEntry(entry *BibEntry, bib *BibTex) (gobEntry, error) {
+	g := gobEntry{Type: entry.TypeSpelling(), CiteName: entry.CiteName}
+	for _, name := range entry.OrderedFieldNames() {
+		value, err := toGobString(entry.Fields[name], bib)
+		if err != nil {
+			return gobEntry{}, err
+		}
+		g.Fields = append(g.Fields, gobField{Name: name, Value: value})
+	}
+	return g, nil
+}
+
+func fromGobEntry(g gobEntry, vars map[string]*BibVar) *BibEntry {
+	entry := NewBibEntry(g.Type, g.CiteName)
+	for _, field := range g.Fields {
+		entry.AddField(field.Name, fromGobString(field.Value, vars))
+	}
+	return entry
+}
+
+// gobEncode encodes v with a new gob encoder.
+func gobEncode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// gobDecode decodes data into v with a new gob decoder.
+func gobDecode(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// GobEncode implements gob.GobEncoder.
+func (c BibConst) GobEncode() ([]byte, error) {
+	return []byte(c), nil
+}
+
+// GobDecode implements gob.GobDecoder.
+func (c *BibConst) GobDecode(data []byte) error {
+	*c = BibConst(data)
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder. The value of the variable is encoded
+// along with its key.
+func (v *BibVar) GobEncode() ([]byte, error) {
+	g, err := toGobString(v, nil)
+	if err != nil {
+		return nil, err
+	}
+	return gobEncode(g)
+}
+
+// GobDecode implements gob.GobDecoder.
+func (v *BibVar) GobDecode(data []byte) error {
+	var g gobString
+	if err := gobDecode(data, &g); err != nil {
+		return err
+	}
+	*v = *newGobVar(g, nil)
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder.
+func (c *BibComposite) GobEncode() ([]byte, error) {
+	g, err := toGobString(c, nil)
+	if err != nil {
+		return nil, err
+	}
+	return gobEncode(g)
+}
+
+// GobDecode implements gob.GobDecoder.
+func (c *BibComposite) GobDecode(data []byte) error {
+	var g gobString
+	if err := gobDecode(data, &g); err != nil {
+		return err
+	}
+	comp, _ := fromGobString(g, nil).(*BibComposite)
+	if comp == nil {
+		comp = &BibComposite{}
+	}
+	*c = *comp
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder. String variables in the fields are
+// encoded with their values.
+func (entry *BibEntry) GobEncode() ([]byte, error) {
+	g, err := toGobEntry(entry, nil)
+	if err != nil {
+		return nil, err
+	}
+	return gobEncode(g)
+}
+
+// GobDecode implements gob.GobDecoder.
+func (entry *BibEntry) GobDecode(data []byte) error {
+	var g gobEntry
+	if err := gobDecode(data, &g); err != nil {
+		return err
+	}
+	*entry = *fromGobEntry(g, nil)
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder, so that a BibTex can be cached with
+// gob.NewEncoder(w).Encode(bib).
+func (bib *BibTex) GobEncode() ([]byte, error) {
+	g := gobBibTex{
+		Preambles: make([]gobString, len(bib.Preambles)),
+		Entries:   make([]gobEntry, len(bib.Entries)),
+		Comments:  bib.Comments,
+	}
+	for i := range bib.Comments {
+		g.CommentPos = append(g.CommentPos, bib.commentPosition(i))
+	}
+	var err error
+	for i, p := range bib.Preambles {
+		if g.Preambles[i], err = toGobString(p, bib); err != nil {
+			return nil, err
+		}
+	}
+	for i, entry := range bib.Entries {
+		if g.Entries[i], err = toGobEntry(entry, bib); err != nil {
+			return nil, err
+		}
+	}
+	for _, key := range bib.StringVarKeys() {
+		sv, err := toGobString(bib.StringVar[key], bib)
+		if err != nil {
+			return nil, err
+		}
+		g.StringVars = append(g.StringVars, sv)
+	}
+	return gobEncode(g)
+}
+
+// GobDecode implements gob.GobDecoder. String variables used in entries are
+// shared with StringVar, as in a parsed BibTex.
+func (bib *BibTex) GobDecode(data []byte) error {
+	var g gobBibTex
+	if err := gobDecode(data, &g); err != nil {
+		return err
+	}
+	decoded := NewBibTex()
+	for _, sv := range g.StringVars {
+		decoded.AddStringVar(sv.Key, nil)
+	}
+	for _, sv := range g.StringVars { // Values may refer to later variables.
+		if sv.Value != nil {
+			decoded.StringVar[sv.Key].Value = fromGobString(*sv.Value, decoded.StringVar)
+		}
+	}
+	for _, p := range g.Preambles {
+		decoded.AddPreamble(fromGobString(p, decoded.StringVar))
+	}
+	for _, entry := range g.Entries {
+		decoded.AddEntry(fromGobEntry(entry, decoded.StringVar))
+	}
+	decoded.Comments = g.Comments
+	decoded.commentPos = g.CommentPos
+	*bib = *decoded
+	return nil
+}
@@ -0,0 +1,113 @@
+package bibtex
+
+import (
+	"html/template"
+	"strings"
+)
+
+// DefaultHTMLTemplate renders the entries as a list in a style similar to APA.
+const DefaultHTMLTemplate = `<ul class="bibliography">
+{{- range $entry := .}}
+  <li id="{{.Key}}">
+    {{- with .Authors}}{{.}} {{end}}
+    {{- with index .Fields "year"}}({{.}}). {{end}}
+    {{- with index .Fields "title"}}{{.}}. {{end}}
+    {{- with index .Fields "journal"}}<em>{{.}}</em>{{end}}
+    {{- with index .Fields "booktitle"}}In <em>{{.}}</em>{{end}}
+    {{- with index .Fields "volume"}}, {{.}}{{end}}
+    {{- with index .Fields "pages"}}, {{.}}{{end}}.
+    {{- with index .Fields "doi"}} {{with $entry.Links.doi}}<a href="{{.}}">{{end}}doi:{{.}}{{if $entry.Links.doi}}</a>{{end}}{{end}}
+    {{- with index .Fields "url"}} {{with $entry.Links.url}}<a href="{{.}}">{{end}}{{.}}{{if $entry.Links.url}}</a>{{end}}{{end}}
+  </li>
+{{- end}}
+</ul>
+`
+
+// TemplateEntry is the data for an entry passed to the template of ToHTML.
+type TemplateEntry struct {
+	Type    string            // Entry type, e.g. article.
+	Key     string            // Cite name.
+	Authors string            // Authors formatted by FormatAuthors with LastInitials, e.g. "Last, F., Last, F., and Last, F.".
+	Fields  map[string]string // Field values with string variables resolved and braces removed.
+	Links   map[string]string // URLs of the doi and url fields, if LinkifyIdentifiers is set.
+}
+
+// ExportOptions are options for ToHTMLWithOptions and ToMarkdownWithOptions.
+type ExportOptions struct {
+	// LinkifyIdentifiers links the doi field to its https://doi.org resolver
+	// URL (built from the DOI without prefix, escaped as needed) and the url
+	// field to itself if it is an http, https or ftp URL. Otherwise both are
+	// written as text. BibTeX output never changes these fields.
+	LinkifyIdentifiers bool
+
+	// StripMathDelimiters writes math in field values, e.g. $O(n\log n)$ or
+	// \(x^2\), without its delimiters but otherwise unchanged. By default math
+	// is written verbatim.
+	StripMathDelimiters bool
+}
+
+// ToHTML renders the entries with the html/template tmpl, which is executed
+// with a []TemplateEntry in document order. If tmpl is empty,
+// DefaultHTMLTemplate is used. Values are HTML-escaped by the template engine.
+// DOIs and URLs are linked (see ExportOptions).
+func (bib *BibTex) ToHTML(tmpl string) (string, error) {
+	return bib.ToHTMLWithOptions(tmpl, ExportOptions{LinkifyIdentifiers: true})
+}
+
+// ToHTMLWithOptions is like ToHTML but with the given options.
+func (bib *BibTex) ToHTMLWithOptions(tmpl string, opts ExportOptions) (string, error) {
+	if tmpl == "" {
+		tmpl = DefaultHTMLTemplate
+	}
+	t, err := template.New("bibtex").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	bib.logNameErrors("ToHTML")
+	entries := make([]TemplateEntry, len(bib.Entries))
+	for i, entry := range bib.Entries {
+		entries[i] = newTemplateEntry(entry, opts)
+	}
+	var buf strings.Builder
+	if err := t.Execute(&buf, entries); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// newTemplateEntry creates the template data for entry.
+func newTemplateEntry(entry *BibEntry, opts ExportOptions) TemplateEntry {
+	te := TemplateEntry{
+		Type:   entry.Type,
+		Key:    entry.CiteName,
+		Fields: make(map[string]string, len(entry.Fields)),
+	}
+	for name, val := range entry.Fields {
+		s := strings.TrimSpace(val.String())
+		if opts.StripMathDelimiters {
+			s = stripMathDelimiters(s)
+		}
+		te.Fields[name] = stripBraces(s)
+	}
+	if opts.LinkifyIdentifiers {
+		te.Links = make(map[string]string)
+		if doi := doiURL(te.Fields["doi"]); doi != "" {
+			te.Links["doi"] = doi
+		}
+		if u := linkURL(te.Fields["url"]); u != "" {
+			te.Links["url"] = u
+		}
+	}
+	if author, ok := te.Fields["author"]; ok {
+		te.Authors = author
+		if authors, err := ParseAuthors(entry.Fields["author"].String()); err == nil {
+			te.Authors = stripBraces(FormatAuthors(authors, 0, LastInitials))
+		}
+	}
+	return te
+}
+
+// stripBraces removes the grouping braces from s.
+func stripBraces(s string) string {
+	return strings.NewReplacer("{", "", "}", "").Replace(s)
+}
@@ -0,0 +1,173 @@
+package bibtex
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// compactIdentifier removes hyphens and spaces from an ISBN or ISSN and
+// upper-cases the check digit X.
+func compactIdentifier(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '-', ' ', '‐', '‑':
+			return -1
+		case 'x':
+			return 'X'
+		}
+		return r
+	}, strings.TrimSpace(s))
+}
+
+// checkDigits returns the digits of s (an X counts as 10 if allowed as the
+// last character), or false if s contains any other character.
+func checkDigits(s string) ([]int, bool) {
+	digits := make([]int, len(s))
+	for i, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits[i] = int(r - '0')
+		case r == 'X' && i == len(s)-1:
+			digits[i] = 10
+		default:
+			return nil, false
+		}
+	}
+	return digits, true
+}
+
+// ValidateISBN returns true if s is an ISBN-10 or ISBN-13 with a valid check
+// digit. Hyphens and spaces are ignored.
+func ValidateISBN(s string) bool {
+	s = compactIdentifier(s)
+	digits, ok := checkDigits(s)
+	if !ok {
+		return false
+	}
+	sum := 0
+	switch len(digits) {
+	case 10:
+		for i, d := range digits {
+			sum += (10 - i) * d
+		}
+		return sum%11 == 0
+	case 13:
+		if digits[12] == 10 {
+			return false
+		}
+		for i, d := range digits {
+			if i%2 == 1 {
+				d *= 3
+			}
+			sum += d
+		}
+		return sum%10 == 0
+	}
+	return false
+}
+
+// NormalizeISBN returns the ISBN s without hyphens and spaces and with an
+// upper-case check digit, e.g. "0-201-03801-3" becomes "0201038013". If s is
+// not a valid ISBN, it is returned unchanged.
+func NormalizeISBN(s string) string {
+	if !ValidateISBN(s) {
+		return s
+	}
+	return compactIdentifier(s)
+}
+
+// ValidateISSN returns true if s is an ISSN with a valid check digit, e.g.
+// "0317-8471". Hyphens and spaces are ignored.
+func ValidateISSN(s string) bool {
+	digits, ok := checkDigits(compactIdentifier(s))
+	if !ok || len(digits) != 8 {
+		return false
+	}
+	sum := 0
+	for i, d := range digits {
+		sum += (8 - i) * d
+	}
+	return sum%11 == 0
+}
+
+// identifierValidators are the fields checked by ValidateIdentifiers.
+var identifierValidators = []struct {
+	field    string
+	validate func(string) bool
+}{
+	{"isbn", ValidateISBN},
+	{"issn", ValidateISSN},
+}
+
+// ValidateIdentifiers checks the isbn and issn fields of all entries, and
+// returns an error wrapping ErrInvalidIdentifier for each invalid one.
+func (bib *BibTex) ValidateIdentifiers() []error {
+	var errs []error
+	for _, entry := range bib.Entries {
+		for _, v := range identifierValidators {
+			val, ok := entry.Fields[v.field]
+			if !ok {
+				continue
+			}
+			if s := val.String(); !v.validate(s) {
+				errs = append(errs, fmt.Errorf("%w: %s: %s %q", ErrInvalidIdentifier, entry.CiteName, v.field, s))
+			}
+		}
+	}
+	return errs
+}
+
+// doiPrefixes are the prefixes a DOI may be written with, e.g. as a URL.
+var doiPrefixes = []string{"https://doi.org/", "http://doi.org/", "https://dx.doi.org/", "http://dx.doi.org/", "doi:"}
+
+// trimDOIPrefix returns doi without surrounding whitespace and a URL or doi:
+// prefix, e.g. "https://doi.org/10.1000/xyz" becomes "10.1000/xyz".
+func trimDOIPrefix(doi string) string {
+	doi = strings.TrimSpace(doi)
+	for _, prefix := range doiPrefixes {
+		if len(doi) >= len(prefix) && strings.EqualFold(doi[:len(prefix)], prefix) {
+			doi = strings.TrimSpace(doi[len(prefix):])
+		}
+	}
+	return doi
+}
+
+// doiURL returns the https://doi.org URL resolving doi (with or without a
+// prefix), with characters that are not allowed in a URL path escaped, or an
+// empty string if doi is empty.
+func doiURL(doi string) string {
+	if doi = trimDOIPrefix(doi); doi == "" {
+		return ""
+	}
+	return (&url.URL{Scheme: "https", Host: "doi.org", Path: "/" + doi}).String()
+}
+
+// linkURL returns u (without surrounding whitespace) if it is an absolute
+// http, https or ftp URL that can be linked to, and an empty string otherwise.
+func linkURL(u string) string {
+	parsed, err := url.Parse(strings.TrimSpace(u))
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+	switch strings.ToLower(parsed.Scheme) {
+	case "http", "https", "ftp":
+		return parsed.String()
+	}
+	return ""
+}
+
+// FindByDOI returns the first entry with the DOI doi, or nil if there is none.
+// DOIs are compared without URL or doi: prefixes and case-insensitively, so
+// "http://dx.doi.org/10.1000/XYZ" finds an entry with doi 10.1000/xyz.
+func (bib *BibTex) FindByDOI(doi string) *BibEntry {
+	if doi = trimDOIPrefix(doi); doi == "" {
+		return nil
+	}
+	for _, entry := range bib.Entries {
+		if d, ok := entry.field("doi"); ok && strings.EqualFold(trimDOIPrefix(inlineString(d)), doi) {
+			return entry
+		}
+	}
+	return nil
+}
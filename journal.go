@@ -0,0 +1,102 @@
+package bibtex
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// JournalResolver maps a journal name to another form, e.g. its abbreviation.
+type JournalResolver interface {
+	// Resolve returns the replacement for the journal name, or false if the
+	// journal is unknown.
+	Resolve(name string) (string, bool)
+}
+
+// MapJournalResolver is a JournalResolver backed by a map from journal names
+// to their replacements. Names must match exactly.
+type MapJournalResolver map[string]string
+
+// Resolve looks up name in the map.
+func (m MapJournalResolver) Resolve(name string) (string, bool) {
+	replacement, ok := m[name]
+	return replacement, ok
+}
+
+// foldJournalResolver is a MapJournalResolver with lowercase keys that
+// matches names case-insensitively.
+type foldJournalResolver map[string]string
+
+func (m foldJournalResolver) Resolve(name string) (string, bool) {
+	replacement, ok := m[strings.ToLower(name)]
+	return replacement, ok
+}
+
+// ApplyJournalResolver replaces the journal field of every entry with the
+// value returned by r, and returns the number of entries changed. Journals
+// unknown to r are left unchanged.
+func (bib *BibTex) ApplyJournalResolver(r JournalResolver) int {
+	changed := 0
+	for _, entry := range bib.Entries {
+		journal, ok := entry.Fields["journal"]
+		if !ok {
+			continue
+		}
+		if replacement, found := r.Resolve(strings.TrimSpace(journal.String())); found {
+			entry.AddField("journal", NewBibConst(replacement))
+			changed++
+		}
+	}
+	return changed
+}
+
+// AbbreviateJournals rewrites the journal field of every entry using table,
+// which maps full journal names to their abbreviations. Names are matched
+// case-insensitively, journals not in table are left unchanged.
+func (bib *BibTex) AbbreviateJournals(table map[string]string) {
+	lookup := make(foldJournalResolver, len(table))
+	for name, abbrev := range table {
+		lookup[strings.ToLower(strings.TrimSpace(name))] = abbrev
+	}
+	bib.ApplyJournalResolver(lookup)
+}
+
+// ReadJournalAbbreviations reads a journal abbreviation list in the format
+// used by JabRef, where each line is either
+//
+//	Full Journal Name;Abbreviation
+//
+// or the older
+//
+//	Full Journal Name = Abbreviation
+//
+// Blank lines and lines starting with # are ignored. The result can be passed
+// to AbbreviateJournals.
+func ReadJournalAbbreviations(r io.Reader) (map[string]string, error) {
+	table := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sep := ";"
+		if !strings.Contains(line, sep) {
+			sep = "="
+		}
+		parts := strings.SplitN(line, sep, 3) // Newer lists may add a third column.
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("%w: line %d: %q", ErrInvalidAbbreviation, lineno, line)
+		}
+		name, abbrev := strings.Trim(strings.TrimSpace(parts[0]), `"`), strings.Trim(strings.TrimSpace(parts[1]), `"`)
+		if name == "" || abbrev == "" {
+			return nil, fmt.Errorf("%w: line %d: %q", ErrInvalidAbbreviation, lineno, line)
+		}
+		table[name] = abbrev
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
@@ -0,0 +1,180 @@
+package bibtex
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// jsonMember is a member of a JSON object.
+type jsonMember struct {
+	name  string
+	value interface{}
+}
+
+// jsonObject is a JSON object that keeps the order of its members, unlike a
+// map which encoding/json writes in sorted order.
+type jsonObject []jsonMember
+
+// MarshalJSON implements json.Marshaler.
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(m.name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// decodeJSONObject calls fn with each member of the JSON object in data, in
+// order.
+func decodeJSONObject(data []byte, fn func(name string, value json.RawMessage) error) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil {
+		return err
+	} else if tok != json.Delim('{') {
+		return fmt.Errorf("%w: expected JSON object but got %v", ErrInvalidField, tok)
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return err
+		}
+		if err := fn(tok.(string), value); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
+
+// MarshalJSON implements json.Marshaler. An entry is an object with the type,
+// the key and an object of the fields (in order, with string variables
+// resolved), e.g.
+//
+//	{"type":"article","key":"knuth1984","fields":{"title":"Literate Programming"}}
+func (entry *BibEntry) MarshalJSON() ([]byte, error) {
+	fields := jsonObject{}
+	for _, name := range entry.OrderedFieldNames() {
+		fields = append(fields, jsonMember{name, inlineString(entry.Fields[name])})
+	}
+	return jsonObject{{"type", entry.Type}, {"key", entry.CiteName}, {"fields", fields}}.MarshalJSON()
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The fields are added in the
+// order of the object.
+func (entry *BibEntry) UnmarshalJSON(data []byte) error {
+	var typ, key string
+	var fields [][2]string // Names and values.
+	err := decodeJSONObject(data, func(name string, value json.RawMessage) error {
+		switch name {
+		case "type":
+			return json.Unmarshal(value, &typ)
+		case "key":
+			return json.Unmarshal(value, &key)
+		case "fields":
+			return decodeJSONObject(value, func(name string, value json.RawMessage) error {
+				var s string
+				if err := json.Unmarshal(value, &s); err != nil {
+					return fmt.Errorf("%w: %s: %v", ErrInvalidField, name, err)
+				}
+				fields = append(fields, [2]string{name, s})
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	decoded := NewBibEntry(typ, key)
+	for _, field := range fields {
+		decoded.AddField(field[0], NewBibConst(field[1]))
+	}
+	*entry = *decoded
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. The bibliography is an object with
+// the entries in order (see BibEntry.MarshalJSON), the string variables in
+// order of definition and the preambles, all with string variables resolved:
+//
+//	{"entries":[...],"strings":{"acm":"ACM"},"preambles":["..."]}
+//
+// The output only depends on the contents of bib, so it can be compared with
+// diff. Comments are not included.
+func (bib *BibTex) MarshalJSON() ([]byte, error) {
+	entries := bib.Entries
+	if entries == nil {
+		entries = []*BibEntry{}
+	}
+	strs := jsonObject{}
+	for _, key := range bib.StringVarKeys() {
+		strs = append(strs, jsonMember{key, inlineString(bib.StringVar[key].Value)})
+	}
+	preambles := make([]string, len(bib.Preambles))
+	for i, p := range bib.Preambles {
+		preambles[i] = inlineString(p)
+	}
+	return jsonObject{{"entries", entries}, {"strings", strs}, {"preambles", preambles}}.MarshalJSON()
+}
+
+// UnmarshalJSON implements json.Unmarshaler, reconstructing a bibliography
+// written by MarshalJSON. Values are constants, as string variables were
+// resolved.
+func (bib *BibTex) UnmarshalJSON(data []byte) error {
+	decoded := NewBibTex()
+	err := decodeJSONObject(data, func(name string, value json.RawMessage) error {
+		switch name {
+		case "entries":
+			var entries []*BibEntry
+			if err := json.Unmarshal(value, &entries); err != nil {
+				return err
+			}
+			for _, entry := range entries {
+				decoded.AddEntry(entry)
+			}
+		case "strings":
+			return decodeJSONObject(value, func(key string, value json.RawMessage) error {
+				var s string
+				if err := json.Unmarshal(value, &s); err != nil {
+					return fmt.Errorf("%w: @string %s: %v", ErrInvalidField, key, err)
+				}
+				decoded.AddStringVar(key, NewBibConst(s))
+				return nil
+			})
+		case "preambles":
+			var preambles []string
+			if err := json.Unmarshal(value, &preambles); err != nil {
+				return err
+			}
+			for _, p := range preambles {
+				decoded.AddPreamble(NewBibConst(p))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	*bib = *decoded
+	return nil
+}
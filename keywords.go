@@ -0,0 +1,82 @@
+package bibtex
+
+import (
+	"strings"
+	"unicode"
+)
+
+var (
+	// KeywordSeparators are the characters that separate keywords.
+	KeywordSeparators = ",;"
+	// KeywordJoiner is the separator used when keywords are written back.
+	KeywordJoiner = ", "
+)
+
+// keywordFields are the field names used for keywords, "keywords" being the
+// canonical name.
+var keywordFields = []string{"keywords", "keyword"}
+
+// Keywords returns the keywords of the entry, split by KeywordSeparators.
+// Both the keywords and keyword fields are read. Keywords are trimmed, empty
+// keywords are dropped and duplicates are removed case-insensitively (the
+// first spelling wins).
+func (entry *BibEntry) Keywords() []string {
+	var keywords []string
+	for _, name := range keywordFields {
+		if val, ok := entry.Fields[name]; ok {
+			keywords = append(keywords, strings.FieldsFunc(val.String(), func(r rune) bool {
+				return strings.ContainsRune(KeywordSeparators, r)
+			})...)
+		}
+	}
+	return normaliseKeywords(keywords)
+}
+
+// SetKeywords replaces the keywords of the entry with the given keywords,
+// joined by KeywordJoiner. The keywords are normalised as in Keywords and the
+// non-canonical keyword field is removed. If there are no keywords left, the
+// keywords field is removed.
+func (entry *BibEntry) SetKeywords(keywords []string) {
+	for _, name := range keywordFields {
+		delete(entry.Fields, name)
+	}
+	if keywords = normaliseKeywords(keywords); len(keywords) > 0 {
+		entry.AddField(keywordFields[0], NewBibConst(strings.Join(keywords, KeywordJoiner)))
+	}
+}
+
+// normaliseKeywords trims keywords and removes empty and duplicate keywords.
+func normaliseKeywords(keywords []string) []string {
+	var normalised []string
+	seen := make(map[string]bool)
+	for _, kw := range keywords {
+		kw = strings.TrimSpace(kw)
+		if kw == "" || seen[strings.ToLower(kw)] {
+			continue
+		}
+		seen[strings.ToLower(kw)] = true
+		normalised = append(normalised, kw)
+	}
+	return normalised
+}
+
+// WordsInTitle returns the distinct words of the title of the entry in order
+// of first appearance, lower-cased, with LaTeX markup converted (see
+// LaTeXToUnicode) and split on whitespace and punctuation, e.g. for indexing.
+// It returns nil if the entry has no title.
+func (entry *BibEntry) WordsInTitle() []string {
+	title, ok := entry.field("title")
+	if !ok {
+		return nil
+	}
+	var words []string
+	seen := make(map[string]bool)
+	notWord := func(r rune) bool { return !unicode.In(r, unicode.L, unicode.M, unicode.N) }
+	for _, word := range strings.FieldsFunc(LaTeXToUnicode(inlineString(title)), notWord) {
+		if word = strings.ToLower(word); !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+	return words
+}
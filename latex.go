@@ -0,0 +1,213 @@
+package bibtex
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// latexAccents maps LaTeX accent commands to Unicode combining characters.
+var latexAccents = map[string]rune{
+	"`":  '\u0300',
+	"'":  '\u0301',
+	"^":  '\u0302',
+	"~":  '\u0303',
+	"=":  '\u0304',
+	"u":  '\u0306',
+	".":  '\u0307',
+	"\"": '\u0308',
+	"r":  '\u030a',
+	"H":  '\u030b',
+	"v":  '\u030c',
+	"d":  '\u0323',
+	"c":  '\u0327',
+	"k":  '\u0328',
+	"b":  '\u0331',
+}
+
+// latexSymbols maps LaTeX commands without arguments to their text.
+var latexSymbols = map[string]string{
+	"ss": "ß", "o": "ø", "O": "Ø", "ae": "æ", "AE": "Æ", "oe": "œ", "OE": "Œ",
+	"aa": "å", "AA": "Å", "l": "ł", "L": "Ł", "i": "ı", "j": "ȷ",
+	"&": "&", "%": "%", "$": "$", "#": "#", "_": "_", "{": "{", "}": "}",
+	" ": " ", "textendash": "–", "textemdash": "—", "dots": "…", "ldots": "…",
+	"textquoteleft": "‘", "textquoteright": "’", "S": "§", "P": "¶",
+	"textbraceleft": "{", "textbraceright": "}",
+	"copyright": "©", "pounds": "£", "euro": "€", "TeX": "TeX", "LaTeX": "LaTeX",
+}
+
+// LaTeXToUnicode converts the LaTeX markup in s to plain Unicode text, e.g.
+// "{\"U}ber---{\ss}" becomes "Über—ß". Accents and special letters are
+// converted to (NFC-normalised) Unicode characters, escaped characters are
+// unescaped, dashes, quotes and ~ are converted to their typographic
+// characters and grouping braces are removed. Other commands (e.g. \emph) are
+// removed, keeping their arguments. Math, i.e. $...$, $$...$$, \(...\) and
+// \[...\], is kept verbatim with its delimiters, and \$ is a dollar sign.
+func LaTeXToUnicode(s string) string {
+	return latexToUnicode(s, false)
+}
+
+// latexToUnicode converts s as LaTeXToUnicode, removing the delimiters of math
+// if stripMath is set.
+func latexToUnicode(s string, stripMath bool) string {
+	var buf strings.Builder
+	convertLaTeX(&buf, []rune(s), stripMath)
+	return norm.NFC.String(buf.String())
+}
+
+// convertLaTeX writes the conversion of s to buf.
+func convertLaTeX(buf *strings.Builder, s []rune, stripMath bool) {
+	for i := 0; i < len(s); i++ {
+		if inner, end, ok := mathSpan(s, i); ok {
+			if stripMath {
+				buf.WriteString(string(inner))
+			} else {
+				buf.WriteString(string(s[i : end+1]))
+			}
+			i = end
+			continue
+		}
+		switch ch := s[i]; {
+		case ch == '\\' && i+1 < len(s):
+			var name string
+			name, i = latexCommand(s, i+1)
+			if mark, ok := latexAccents[name]; ok {
+				var arg []rune
+				arg, i = latexArgument(s, i+1)
+				var converted strings.Builder
+				convertLaTeX(&converted, arg, stripMath)
+				base := []rune(converted.String())
+				if len(base) == 0 {
+					buf.WriteRune(mark)
+					continue
+				}
+				switch base[0] { // Accents on dotless i and j, e.g. \'\i.
+				case 'ı':
+					base[0] = 'i'
+				case 'ȷ':
+					base[0] = 'j'
+				}
+				buf.WriteRune(base[0])
+				buf.WriteRune(mark)
+				buf.WriteString(string(base[1:]))
+			} else if symbol, ok := latexSymbols[name]; ok {
+				buf.WriteString(symbol)
+			}
+		case ch == '{' || ch == '}':
+		case ch == '~':
+			buf.WriteRune('\u00a0') // No-break space.
+		case ch == '-' && i+2 < len(s) && s[i+1] == '-' && s[i+2] == '-':
+			buf.WriteRune('—')
+			i += 2
+		case ch == '-' && i+1 < len(s) && s[i+1] == '-':
+			buf.WriteRune('–')
+			i++
+		case ch == '`' && i+1 < len(s) && s[i+1] == '`':
+			buf.WriteRune('“')
+			i++
+		case ch == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			buf.WriteRune('”')
+			i++
+		default:
+			buf.WriteRune(ch)
+		}
+	}
+}
+
+// mathSpan returns the contents of the math starting at s[start], delimited by
+// $, $$, \( and \) or \[ and \], and the index of the last rune of its
+// closing delimiter. It returns false if there is no math at s[start] or it is
+// not closed. Escaped dollar signs (\$) do not delimit math.
+func mathSpan(s []rune, start int) (inner []rune, end int, ok bool) {
+	var left, right string
+	switch {
+	case s[start] == '$' && start+1 < len(s) && s[start+1] == '$':
+		left, right = "$$", "$$"
+	case s[start] == '$':
+		left, right = "$", "$"
+	case s[start] == '\\' && start+1 < len(s) && s[start+1] == '(':
+		left, right = "\\(", "\\)"
+	case s[start] == '\\' && start+1 < len(s) && s[start+1] == '[':
+		left, right = "\\[", "\\]"
+	default:
+		return nil, 0, false
+	}
+	for i := start + len(left); i+len(right) <= len(s); i++ {
+		if s[i] == '\\' && right[0] == '$' { // An escaped character, e.g. \$.
+			i++
+			continue
+		}
+		if string(s[i:i+len(right)]) == right {
+			return s[start+len(left) : i], i + len(right) - 1, true
+		}
+	}
+	return nil, 0, false
+}
+
+// stripMathDelimiters removes the delimiters of math in s (see mathSpan),
+// keeping its contents.
+func stripMathDelimiters(s string) string {
+	r := []rune(s)
+	var buf strings.Builder
+	for i := 0; i < len(r); i++ {
+		if r[i] == '\\' && i+1 < len(r) && r[i+1] == '$' {
+			buf.WriteString("\\$")
+			i++
+		} else if inner, end, ok := mathSpan(r, i); ok {
+			buf.WriteString(string(inner))
+			i = end
+		} else {
+			buf.WriteRune(r[i])
+		}
+	}
+	return buf.String()
+}
+
+// latexCommand reads the name of the command starting at s[start] (after the
+// backslash), and returns it with the index of its last rune. Whitespace after
+// a command name of letters is skipped, as in TeX.
+func latexCommand(s []rune, start int) (string, int) {
+	if !unicode.IsLetter(s[start]) {
+		return string(s[start]), start
+	}
+	end := start
+	for end < len(s) && unicode.IsLetter(s[end]) && s[end] < unicode.MaxASCII {
+		end++
+	}
+	name := string(s[start:end])
+	for end < len(s) && s[end] == ' ' {
+		end++
+	}
+	return name, end - 1
+}
+
+// latexArgument reads the argument starting at s[start], either a braced
+// group or a single character, and returns it with the index of its last rune.
+func latexArgument(s []rune, start int) ([]rune, int) {
+	for start < len(s) && s[start] == ' ' {
+		start++
+	}
+	if start >= len(s) {
+		return nil, len(s) - 1
+	}
+	if s[start] == '\\' && start+1 < len(s) { // A command, e.g. \'\i.
+		_, end := latexCommand(s, start+1)
+		return s[start : end+1], end
+	}
+	if s[start] != '{' {
+		return s[start : start+1], start
+	}
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return s[start+1 : i], i
+			}
+		}
+	}
+	return s[start+1:], len(s) - 1
+}
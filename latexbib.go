@@ -0,0 +1,159 @@
+package bibtex
+
+import (
+	"io"
+	"strings"
+	"unicode"
+)
+
+// CitationStyle is a BibTeX style for WriteLatex.
+type CitationStyle int
+
+const (
+	// Plain labels entries with numbers, as the plain BibTeX style.
+	Plain CitationStyle = iota
+	// Alpha labels entries with the authors and year, e.g. [Knu84], as the
+	// alpha BibTeX style.
+	Alpha
+)
+
+// WriteLatex writes each entry (in document order) as a \bibitem followed by
+// the formatted reference, in the given style, for a thebibliography
+// environment. Values are written as LaTeX, with string variables resolved.
+func (bib *BibTex) WriteLatex(w io.Writer, style CitationStyle) error {
+	bib.logNameErrors("WriteLatex")
+	var labels []string
+	if style == Alpha {
+		labels = alphaLabels(bib.Entries)
+	}
+	var buf strings.Builder
+	for i, entry := range bib.Entries {
+		buf.WriteString(`\bibitem`)
+		if labels != nil {
+			buf.WriteString("[" + labels[i] + "]")
+		}
+		buf.WriteString("{" + entry.CiteName + "}\n")
+		buf.WriteString(latexReference(entry))
+		buf.WriteString("\n\n")
+	}
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
+
+// latexReference formats entry as the blocks of a reference separated by
+// \newblock: the authors, the title and where it was published.
+func latexReference(entry *BibEntry) string {
+	field := func(name string) string {
+		val, _ := entry.DisplayField(name)
+		return strings.TrimSpace(val)
+	}
+	var blocks []string
+	if author := field("author"); author != "" {
+		if authors, err := ParseAuthors(author); err == nil {
+			author = FormatAuthors(authors, 0, FullName)
+		}
+		blocks = append(blocks, author)
+	}
+	if title := field("title"); title != "" {
+		if bookTypes[strings.ToLower(entry.Type)] {
+			title = `{\em ` + title + `}`
+		}
+		blocks = append(blocks, title)
+	}
+	volume := field("volume")
+	if number := field("number"); number != "" {
+		volume += "(" + number + ")"
+	}
+	var venue []string
+	switch {
+	case field("journal") != "":
+		pages := field("pages")
+		if volume != "" && pages != "" {
+			volume, pages = volume+":"+pages, ""
+		}
+		venue = nonEmpty(`{\em `+field("journal")+`}`, volume, pages)
+	case field("booktitle") != "":
+		venue = nonEmpty(`In {\em `+field("booktitle")+`}`, volume)
+		if pages := field("pages"); pages != "" {
+			venue = append(venue, "pages "+pages)
+		}
+	default:
+		venue = nonEmpty(volume)
+	}
+	venue = append(venue, nonEmpty(field("publisher"), field("school"), field("institution"), field("address"), field("year"))...)
+	if v := strings.Join(venue, ", "); v != "" {
+		blocks = append(blocks, v)
+	}
+	for i, block := range blocks {
+		if !strings.HasSuffix(block, ".") && !strings.HasSuffix(block, "?") && !strings.HasSuffix(block, "!") {
+			blocks[i] = block + "."
+		}
+	}
+	return strings.Join(blocks, "\n\\newblock ")
+}
+
+// alphaLabels returns the labels of the entries in the alpha style: the first
+// three letters of the last name of a single author, or the initials of the
+// last names of up to four authors (three and "+" for more), followed by the
+// last two digits of the year. Entries with the same label get a suffix a, b,
+// etc.
+func alphaLabels(entries []*BibEntry) []string {
+	labels := make([]string, len(entries))
+	count := make(map[string]int)
+	for i, entry := range entries {
+		labels[i] = alphaLabel(entry)
+		count[labels[i]]++
+	}
+	next := make(map[string]rune)
+	for i, label := range labels {
+		if count[label] > 1 {
+			if next[label] == 0 {
+				next[label] = 'a'
+			}
+			labels[i] = label + string(next[label])
+			next[label]++
+		}
+	}
+	return labels
+}
+
+// alphaLabel returns the alpha label of entry without a suffix.
+func alphaLabel(entry *BibEntry) string {
+	letters := func(s string, n int) string {
+		var res []rune
+		for _, r := range LaTeXToUnicode(s) {
+			if unicode.IsLetter(r) && len(res) < n {
+				res = append(res, r)
+			}
+		}
+		return string(res)
+	}
+	var label string
+	names, ok := entry.field("author")
+	if !ok {
+		names, ok = entry.field("editor")
+	}
+	var authors []Author
+	if ok {
+		authors, _ = ParseAuthors(inlineString(names))
+	}
+	switch {
+	case len(authors) == 0:
+		label = letters(entry.CiteName, 3)
+	case len(authors) == 1:
+		label = letters(authors[0].Last, 3)
+	default:
+		for i, a := range authors {
+			if i == 3 && len(authors) > 4 || a.IsOthers() {
+				label += "+"
+				break
+			}
+			label += letters(a.Last, 1)
+		}
+	}
+	year, _ := entry.DisplayField("year")
+	if year = strings.TrimSpace(year); len(year) >= 2 {
+		year = year[len(year)-2:]
+	}
+	return label + year
+}
@@ -2,27 +2,165 @@
 
 package bibtex
 
-import "io"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
 
 // Lexer for bibtex.
 type Lexer struct {
 	scanner *Scanner
+	bib     *BibTex  // Parse result.
+	pos     TokenPos // Start of the last token.
+	tok     Token    // The last token.
 	Errors  chan error
+	errs    []ParseError // All errors, including those recovered from.
+
+	entryStart TokenPos // Opening brace of the current entry.
+	inEntry    bool     // Whether the current entry is not closed yet.
+	afterAt    bool     // Whether an @ started an entry not opened yet.
+	valueStart TokenPos // Start of the last value in the current entry.
+	value      string   // The last value in the current entry.
+	isEntry    bool     // Whether the current entry is not @string etc.
+	entryToks  int      // Number of tokens read in the current entry.
+	key        string   // Cite key of the current entry.
+	lit        string   // Literal value of the last token.
+
+	forward          []forwardRef    // References to string vars not yet defined.
+	allowUndefinedSV bool            // Resolve undefined string vars to "".
+	citeKeys         map[string]bool // Cite keys of the entries parsed so far.
+	warnings         []ParseError    // Non-fatal problems found.
+	onWarning        func(error)     // Called with each warning, if set.
+}
+
+// forwardRef is a reference to a string variable used before its definition.
+type forwardRef struct {
+	v   *BibVar
+	pos TokenPos
 }
 
 // NewLexer returns a new yacc-compatible lexer.
 func NewLexer(r io.Reader) *Lexer {
-	return &Lexer{scanner: NewScanner(r), Errors: make(chan error, 1)}
+	return &Lexer{scanner: NewScanner(r), bib: NewBibTex(), Errors: make(chan error, 1), citeKeys: make(map[string]bool)}
 }
 
 // Lex is provided for yacc-compatible parser.
 func (l *Lexer) Lex(yylval *bibtexSymType) int {
-	token, strval := l.scanner.Scan()
+	token, strval, pos := l.scanner.Scan()
 	yylval.strval = strval
+	if l.tok == ATSIGN {
+		l.isEntry = token == BAREIDENT
+	}
+	l.pos, l.tok, l.lit = pos, token, strval
+	l.entryToks++
+	switch token {
+	case ATSIGN:
+		l.afterAt = true
+	case LBRACE, LPAREN:
+		if l.afterAt && !l.inEntry {
+			l.entryStart, l.inEntry = pos, true
+			l.value, l.key, l.entryToks = "", "", 0
+		}
+		l.afterAt = false
+	case RBRACE, RPAREN:
+		l.inEntry, l.afterAt = false, false
+		l.value = ""
+	case IDENT:
+		l.valueStart, l.value = pos, strval
+	case BAREIDENT:
+		if l.inEntry && l.entryToks == 1 {
+			l.key = strval
+		}
+	}
 	return int(token)
 }
 
-// Error handles error.
+// Error handles error. The error is reported as a ParseError at the start of
+// the last token read, and only the first error is sent to Errors. If the
+// scanner found an error in the last token, it is reported instead of err.
+// If the next entry (or the end of input) is reached before the current entry
+// is closed, the error is reported at the opening brace of the entry, or of
+// its last value if that took the closing brace of the entry. An error in the
+// cite key of an entry is reported as ErrInvalidCiteKey.
 func (l *Lexer) Error(err string) {
-	l.Errors <- &ErrParse{Err: err, Pos: l.scanner.pos}
+	e := ParseError{Line: l.pos.Line(), Column: l.pos.Column(), Message: err}
+	switch {
+	case l.scanner.err != nil:
+		e.Message, e.err = l.scanner.err.Error(), l.scanner.err
+		l.scanner.err = nil
+	case l.inEntry && (l.tok == ATSIGN || l.tok == EOF) && closesEntry(l.value):
+		e.err = fmt.Errorf("%w starting at line %d", ErrUnterminatedValue, l.valueStart.Line())
+		e.Line, e.Column, e.Message = l.valueStart.Line(), l.valueStart.Column(), e.err.Error()
+	case l.inEntry && (l.tok == ATSIGN || l.tok == EOF):
+		e.err = fmt.Errorf("%w starting at line %d", ErrUnterminatedEntry, l.entryStart.Line())
+		e.Line, e.Column, e.Message = l.entryStart.Line(), l.entryStart.Column(), e.err.Error()
+	case l.inEntry && l.isEntry && l.entryToks == 1 && l.tok != EOF:
+		e.err = fmt.Errorf("%w: unexpected %q", ErrInvalidCiteKey, l.lit)
+		e.Message = e.err.Error()
+	case l.inEntry && l.isEntry && l.entryToks == 2 && l.tok != EOF && l.tok != ATSIGN:
+		e.err = fmt.Errorf("%w: unexpected %q after %q", ErrInvalidCiteKey, l.lit, l.key)
+		e.Message = e.err.Error()
+	}
+	if l.tok == ATSIGN {
+		l.inEntry = false // The error is recovered from at the @.
+	}
+	l.errs = append(l.errs, e)
+	select {
+	case l.Errors <- e:
+	default:
+	}
+}
+
+// closesEntry returns true if the braced value ended with a brace at the start
+// of a line, which was probably meant to close the entry: an unbalanced brace
+// in the value made the value take the closing brace of the entry.
+func closesEntry(value string) bool {
+	i := strings.LastIndexByte(value, '\n')
+	return i >= 0 && strings.TrimSpace(value[i:]) == ""
+}
+
+// warn records a non-fatal problem err found at pos as a ParseError.
+func (l *Lexer) warn(err error, pos TokenPos) {
+	w := ParseError{Line: pos.Line(), Column: pos.Column(), Message: err.Error(), err: err}
+	l.warnings = append(l.warnings, w)
+	if l.onWarning != nil {
+		l.onWarning(w)
+	}
+}
+
+// forwardStringVar returns a placeholder for the string variable key, which is
+// resolved by resolveStringVars.
+func (l *Lexer) forwardStringVar(key string) *BibVar {
+	v := &BibVar{Key: key, forward: true}
+	l.forward = append(l.forward, forwardRef{v: v, pos: l.pos})
+	return v
+}
+
+// resolveStringVars resolves the references to string variables used before
+// their definition, and returns a ParseError for each reference to a variable
+// that is still undefined (or cyclic). The errors are also recorded in the
+// errors of the lexer.
+func (l *Lexer) resolveStringVars() []ParseError {
+	var undefined []ParseError
+	for _, ref := range l.forward {
+		bv, err := l.bib.GetStringVar(ref.v.Key)
+		if err == nil {
+			ref.v.Value = bv.Value
+			err = checkStringVarCycle(ref.v, map[*BibVar]bool{})
+		}
+		if err != nil {
+			ref.v.Value = NewBibConst("")
+			if errors.Is(err, ErrUnknownStringVar) && l.allowUndefinedSV {
+				l.warn(err, ref.pos)
+				continue
+			}
+			e := ParseError{Line: ref.pos.Line(), Column: ref.pos.Column(), Message: err.Error(), err: err}
+			l.errs = append(l.errs, e)
+			undefined = append(undefined, e)
+		}
+	}
+	l.forward = nil
+	return undefined
 }
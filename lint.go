@@ -0,0 +1,217 @@
+package bibtex
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// LintIssue is a style issue found in an entry.
+type LintIssue struct {
+	CiteName string // Cite name of the entry.
+	Rule     string // Name of the rule that found the issue, e.g. "MissingDOI".
+	Field    string // Field with the issue, empty if it concerns the entry.
+	Message  string // Description of the issue.
+}
+
+func (i LintIssue) String() string {
+	if i.Field == "" {
+		return fmt.Sprintf("%s: %s (%s)", i.CiteName, i.Message, i.Rule)
+	}
+	return fmt.Sprintf("%s: %s: %s (%s)", i.CiteName, i.Field, i.Message, i.Rule)
+}
+
+// LintRule checks an entry and returns the issues found.
+type LintRule func(*BibEntry) []LintIssue
+
+// Lint checks all entries with rules, and returns the issues found in order of
+// the entries and rules. Without rules, all built-in rules are used.
+func (bib *BibTex) Lint(rules ...LintRule) []LintIssue {
+	if len(rules) == 0 {
+		rules = []LintRule{
+			MissingDOI, InconsistentCapitalization, UnprotectedAcronyms,
+			DuplicateCiteKey(), MissingAbstract, NumericPageRange, PageRangeFormat,
+		}
+	}
+	var issues []LintIssue
+	for _, entry := range bib.Entries {
+		for _, rule := range rules {
+			issues = append(issues, rule(entry)...)
+		}
+	}
+	return issues
+}
+
+// lintIssue returns a single issue of entry as found by a rule.
+func lintIssue(entry *BibEntry, rule, field, format string, args ...interface{}) []LintIssue {
+	return []LintIssue{{CiteName: entry.CiteName, Rule: rule, Field: field, Message: fmt.Sprintf(format, args...)}}
+}
+
+// doiTypes are the entry types that usually have a DOI.
+var doiTypes = map[string]bool{"article": true, "inproceedings": true, "incollection": true}
+
+// MissingDOI reports articles and papers in proceedings or collections without
+// a doi field.
+func MissingDOI(entry *BibEntry) []LintIssue {
+	if _, ok := entry.Fields["doi"]; ok || !doiTypes[strings.ToLower(entry.Type)] {
+		return nil
+	}
+	return lintIssue(entry, "MissingDOI", "doi", "missing DOI")
+}
+
+// MissingAbstract reports entries without an abstract field.
+func MissingAbstract(entry *BibEntry) []LintIssue {
+	if _, ok := entry.Fields["abstract"]; ok {
+		return nil
+	}
+	return lintIssue(entry, "MissingAbstract", "abstract", "missing abstract")
+}
+
+// DuplicateCiteKey returns a rule that reports entries with the cite name of
+// an entry checked before (compared case-insensitively). The rule remembers
+// the cite names it has seen, so a new rule is needed for each call to Lint.
+func DuplicateCiteKey() LintRule {
+	seen := make(map[string]bool)
+	return func(entry *BibEntry) []LintIssue {
+		if seen[citeKey(entry.CiteName)] {
+			return lintIssue(entry, "DuplicateCiteKey", "", "duplicate cite key")
+		}
+		seen[citeKey(entry.CiteName)] = true
+		return nil
+	}
+}
+
+// titleWords returns the words of the title of the entry that are not in
+// braces (which BibTeX styles never change case of), without punctuation.
+func titleWords(entry *BibEntry) []string {
+	title, ok := entry.Fields["title"]
+	if !ok {
+		return nil
+	}
+	var words []string
+	for _, word := range splitWords(title.String()) {
+		if strings.ContainsAny(word, "{}") {
+			continue
+		}
+		if word = strings.TrimFunc(word, unicode.IsPunct); word != "" {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// minorWords are the English prepositions and conjunctions of four or more
+// letters that stay lower case in title case, e.g. "with" in "Programming
+// with Types".
+var minorWords = map[string]bool{
+	"about": true, "above": true, "across": true, "after": true, "against": true,
+	"along": true, "among": true, "around": true, "before": true, "behind": true,
+	"below": true, "beneath": true, "beside": true, "between": true, "beyond": true,
+	"down": true, "during": true, "except": true, "from": true, "inside": true,
+	"into": true, "like": true, "near": true, "onto": true, "over": true,
+	"past": true, "since": true, "through": true, "throughout": true,
+	"toward": true, "towards": true, "under": true, "until": true, "upon": true,
+	"versus": true, "with": true, "within": true, "without": true,
+}
+
+// InconsistentCapitalization reports titles that mix title case and sentence
+// case, i.e. some words of four or more letters (after the first word) start
+// with an upper-case letter and others with a lower-case letter. Minor words
+// such as "with" or "from", which are lower case in title case too, are
+// ignored.
+func InconsistentCapitalization(entry *BibEntry) []LintIssue {
+	words := titleWords(entry)
+	var upper, lower []string
+	for i, word := range words {
+		first := []rune(word)[0]
+		if i == 0 || len([]rune(word)) < 4 || !unicode.IsLetter(first) || minorWords[word] {
+			continue
+		}
+		if unicode.IsUpper(first) {
+			upper = append(upper, word)
+		} else {
+			lower = append(lower, word)
+		}
+	}
+	if len(upper) == 0 || len(lower) == 0 {
+		return nil
+	}
+	return lintIssue(entry, "InconsistentCapitalization", "title",
+		"inconsistent capitalization of %q and %q", upper[0], lower[0])
+}
+
+// UnprotectedAcronyms reports words in titles with upper-case letters after
+// the first letter, such as "DNA" or "LaTeX", that are not in braces. BibTeX
+// styles that use sentence case would convert them to lower case.
+func UnprotectedAcronyms(entry *BibEntry) []LintIssue {
+	var acronyms []string
+	for _, word := range titleWords(entry) {
+		for i, r := range []rune(word) {
+			if i > 0 && unicode.IsUpper(r) {
+				acronyms = append(acronyms, word)
+				break
+			}
+		}
+	}
+	if len(acronyms) == 0 {
+		return nil
+	}
+	return lintIssue(entry, "UnprotectedAcronyms", "title",
+		"unprotected acronyms %s", strings.Join(acronyms, ", "))
+}
+
+// pageRangeDashes are the characters that may separate a page range.
+const pageRangeDashes = "-‐‑‒–—"
+
+// splitPageRange splits a page range such as "12--15" into its first page,
+// the separator and its last page. A single page has an empty separator and
+// last page.
+func splitPageRange(pages string) (first, sep, last string) {
+	pages = strings.TrimSpace(pages)
+	start := strings.IndexAny(pages, pageRangeDashes)
+	if start < 0 {
+		return pages, "", ""
+	}
+	rest := strings.TrimLeft(pages[start:], pageRangeDashes)
+	sep = pages[start : len(pages)-len(rest)]
+	return strings.TrimSpace(pages[:start]), sep, strings.TrimSpace(rest)
+}
+
+// NumericPageRange reports pages fields that are not a page number or a range
+// of page numbers with the first page not after the last page.
+func NumericPageRange(entry *BibEntry) []LintIssue {
+	pages, ok := entry.Fields["pages"]
+	if !ok {
+		return nil
+	}
+	first, sep, last := splitPageRange(pages.String())
+	from, err := strconv.Atoi(first)
+	if err != nil {
+		return lintIssue(entry, "NumericPageRange", "pages", "non-numeric page %q", first)
+	}
+	if sep == "" {
+		return nil
+	}
+	to, err := strconv.Atoi(last)
+	if err != nil {
+		return lintIssue(entry, "NumericPageRange", "pages", "non-numeric page %q", last)
+	}
+	if from > to {
+		return lintIssue(entry, "NumericPageRange", "pages", "page range %d to %d is reversed", from, to)
+	}
+	return nil
+}
+
+// PageRangeFormat reports page ranges that are not separated by "--", e.g.
+// "12-15" or "12–15".
+func PageRangeFormat(entry *BibEntry) []LintIssue {
+	pages, ok := entry.Fields["pages"]
+	if !ok {
+		return nil
+	}
+	if _, sep, _ := splitPageRange(pages.String()); sep != "" && sep != "--" {
+		return lintIssue(entry, "PageRangeFormat", "pages", "page range separator %q instead of \"--\"", sep)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package bibtex
+
+import (
+	"log/slog"
+)
+
+// SetLogger sets the logger for problems that do not stop an operation, e.g.
+// an author field that cannot be parsed and is exported verbatim. A nil
+// logger restores the default, slog.Default(). Errors are always returned,
+// never only logged.
+func (bib *BibTex) SetLogger(l *slog.Logger) {
+	bib.log = l
+}
+
+// logger returns the logger of bib.
+func (bib *BibTex) logger() *slog.Logger {
+	if bib.log == nil {
+		return slog.Default()
+	}
+	return bib.log
+}
+
+// logNameErrors logs a warning for each author or editor field that cannot be
+// parsed, for the operation op which falls back to the field as is.
+func (bib *BibTex) logNameErrors(op string) {
+	for _, entry := range bib.Entries {
+		for _, field := range authorFields {
+			val, ok := entry.field(field)
+			if !ok {
+				continue
+			}
+			if _, err := ParseAuthors(inlineString(val)); err != nil {
+				bib.logger().Warn("cannot parse names", "op", op, "entry", entry.CiteName, "field", field, "err", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package bibtex
+
+import (
+	"strings"
+	"text/template"
+)
+
+// MarkdownStyle is a citation style for ToMarkdown.
+type MarkdownStyle int
+
+const (
+	// APA formats entries in the style of the American Psychological
+	// Association, e.g. Knuth, D. E. (1984). Literate programming. *The
+	// Computer Journal*, *27*(2), 97–111.
+	APA MarkdownStyle = iota
+	// MLA formats entries in the style of the Modern Language Association,
+	// e.g. Knuth, Donald E. "Literate Programming." *The Computer Journal*,
+	// vol. 27, no. 2, 1984, pp. 97–111.
+	MLA
+	// Chicago formats entries in the author-date style of the Chicago Manual
+	// of Style, e.g. Knuth, Donald E. 1984. "Literate Programming." *The
+	// Computer Journal* 27 (2): 97–111.
+	Chicago
+)
+
+// markdownTemplates are the templates of the styles, executed with a
+// []markdownEntry. See markdownFuncs for the functions.
+var markdownTemplates = map[MarkdownStyle]*template.Template{
+	APA: markdownTemplate(`{{range .}}- {{with .Authors}}{{md .}}{{period .}} {{end}}({{with .Year}}{{md .}}{{else}}n.d.{{end}}).
+		{{- if .Book}} *{{md .Title}}*{{else}} {{md .Title}}{{end}}{{period .Title}}
+		{{- with list (wrap "*" .Venue "*") (print (wrap "*" .Volume "*") (wrap "(" .Number ")")) (md .Pages)}} {{.}}.{{end}}
+		{{- with .Publisher}} {{md .}}.{{end}}
+		{{- if .DOIURL}} <{{.DOIURL}}>{{else if .DOI}} doi:{{md .DOI}}{{else if .URLLink}} <{{.URLLink}}>{{else if .URL}} {{md .URL}}{{end}}
+{{end}}`),
+	MLA: markdownTemplate(`{{range .}}- {{with .Authors}}{{md .}}{{period .}} {{end}}
+		{{- if .Book}}*{{md .Title}}*{{period .Title}}{{else}}"{{md .Title}}{{period .Title}}"{{end}}
+		{{- with list (wrap "*" .Venue "*") (wrap "vol. " .Volume "") (wrap "no. " .Number "") (md .Publisher) (md .Year) (wrap "pp. " .Pages "")}} {{.}}.{{end}}
+		{{- if .DOIURL}} [doi:{{md .DOI}}]({{link .DOIURL}}){{else if .DOI}} doi:{{md .DOI}}{{else if .URLLink}} <{{.URLLink}}>{{else if .URL}} {{md .URL}}{{end}}
+{{end}}`),
+	Chicago: markdownTemplate(`{{range .}}- {{with .Authors}}{{md .}}{{period .}} {{end}}{{with .Year}}{{md .}}. {{end}}
+		{{- if .Book}}*{{md .Title}}*{{period .Title}}{{else}}"{{md .Title}}{{period .Title}}"{{end}}
+		{{- with .Venue}} *{{md .}}*{{end}}{{with .Volume}} {{md .}}{{end}}{{with .Number}} ({{md .}}){{end}}
+		{{- with .Pages}}: {{md .}}{{end}}{{if or .Venue .Volume .Pages}}.{{end}}
+		{{- with .Publisher}} {{md .}}.{{end}}
+		{{- if .DOIURL}} <{{.DOIURL}}>.{{else if .DOI}} doi:{{md .DOI}}.{{else if .URLLink}} <{{.URLLink}}>.{{else if .URL}} {{md .URL}}.{{end}}
+{{end}}`),
+}
+
+// markdownEscaper escapes the characters with a meaning in Markdown text.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`,
+)
+
+// markdownFuncs are the functions of the Markdown templates:
+//
+//   - md escapes its argument as Markdown text;
+//   - period returns "." unless its argument ends with a punctuation mark;
+//   - wrap returns its second argument escaped between the first and the
+//     third, or "" if it is empty;
+//   - list joins its non-empty arguments with ", ";
+//   - link escapes the parentheses of a URL for a Markdown link.
+var markdownFuncs = template.FuncMap{
+	"md": markdownEscaper.Replace,
+	"period": func(s string) string {
+		if strings.HasSuffix(s, ".") || strings.HasSuffix(s, "?") || strings.HasSuffix(s, "!") {
+			return ""
+		}
+		return "."
+	},
+	"wrap": func(before, s, after string) string {
+		if s == "" {
+			return ""
+		}
+		return before + markdownEscaper.Replace(s) + after
+	},
+	"list": func(items ...string) string {
+		return strings.Join(nonEmpty(items...), ", ")
+	},
+	"link": strings.NewReplacer("(", "%28", ")", "%29").Replace,
+}
+
+// markdownTemplate parses the template text of a style.
+func markdownTemplate(text string) *template.Template {
+	return template.Must(template.New("markdown").Funcs(markdownFuncs).Parse(text))
+}
+
+// bookTypes are the entry types whose title is set in italics, as a book.
+var bookTypes = map[string]bool{
+	"book": true, "booklet": true, "manual": true, "proceedings": true,
+	"phdthesis": true, "mastersthesis": true, "techreport": true,
+}
+
+// markdownEntry is the data for an entry passed to the Markdown templates.
+type markdownEntry struct {
+	Authors   string // Authors formatted in the style.
+	Year      string
+	Title     string
+	Venue     string // Journal or book the entry was published in.
+	Volume    string
+	Number    string
+	Pages     string
+	Publisher string // Publisher (or school or institution) of a book.
+	DOI       string // DOI without prefix.
+	DOIURL    string // Resolver URL of the DOI, if LinkifyIdentifiers is set.
+	URL       string
+	URLLink   string // URL as a link, if LinkifyIdentifiers is set.
+	Book      bool   // Whether the entry is a book, with the title in italics.
+}
+
+// ToMarkdown renders the entries as a Markdown unordered list in the given
+// citation style, one item per entry in document order. LaTeX markup is
+// converted to Unicode (see EntryView), titles of journals and books are in
+// italics and DOIs (or else URLs) are linked.
+func (bib *BibTex) ToMarkdown(style MarkdownStyle) string {
+	return bib.ToMarkdownWithOptions(style, ExportOptions{LinkifyIdentifiers: true})
+}
+
+// ToMarkdownWithOptions is like ToMarkdown but with the given options.
+func (bib *BibTex) ToMarkdownWithOptions(style MarkdownStyle, opts ExportOptions) string {
+	t, ok := markdownTemplates[style]
+	if !ok {
+		t = markdownTemplates[APA]
+	}
+	bib.logNameErrors("ToMarkdown")
+	entries := make([]markdownEntry, len(bib.Entries))
+	for i, entry := range bib.Entries {
+		v := newEntryView(entry, opts.StripMathDelimiters)
+		e := markdownEntry{
+			Authors: markdownAuthors(v.authors, style),
+			Year:    v.Year(),
+			Title:   v.Title(),
+			Volume:  v.Field("volume"),
+			Number:  v.Field("number"),
+			Pages:   v.Field("pages"),
+			DOI:     trimDOIPrefix(v.Field("doi")),
+			URL:     v.Field("url"),
+			Book:    bookTypes[strings.ToLower(v.Type())],
+		}
+		if opts.LinkifyIdentifiers {
+			e.DOIURL, e.URLLink = v.DOIURL(), v.URL()
+		}
+		if v.authors == nil {
+			e.Authors = v.Field("author")
+		}
+		if e.Book {
+			e.Publisher = strings.Join(nonEmpty(v.Field("publisher"), v.Field("school"), v.Field("institution")), ", ")
+		} else {
+			e.Venue = v.Venue()
+		}
+		entries[i] = e
+	}
+	var buf strings.Builder
+	_ = t.Execute(&buf, entries) // Cannot fail writing to a strings.Builder.
+	return buf.String()
+}
+
+// markdownAuthors formats authors in the given style: "Last, F., & Last, F."
+// in APA, "Last, First, et al." for more than two authors in MLA, and "Last,
+// First, First Last, and First Last" in Chicago.
+func markdownAuthors(authors []Author, style MarkdownStyle) string {
+	if len(authors) == 0 {
+		return ""
+	}
+	etAl := len(authors) > 1 && authors[len(authors)-1].IsOthers()
+	if etAl {
+		authors = authors[:len(authors)-1]
+	}
+	names := make([]string, len(authors))
+	for i, a := range authors {
+		switch {
+		case style == APA:
+			names[i] = a.Format(LastInitials)
+		case i == 0 && a.First != "":
+			names[i] = strings.Join(nonEmpty(a.Von, a.Last), " ") + ", " + strings.Join(nonEmpty(a.First, a.Jr), ", ")
+		default:
+			names[i] = a.String()
+		}
+	}
+	switch {
+	case style == MLA && (etAl || len(names) > 2):
+		return names[0] + ", et al."
+	case etAl:
+		return strings.Join(names, ", ") + ", et al."
+	case len(names) == 1:
+		return names[0]
+	case style == APA:
+		return strings.Join(names[:len(names)-1], ", ") + ", & " + names[len(names)-1]
+	case len(names) == 2:
+		return names[0] + ", and " + names[1]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + ", and " + names[len(names)-1]
+}
@@ -0,0 +1,94 @@
+package bibtex
+
+import (
+	"errors"
+	"io"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// ParseOptions are options for ParseWithOptions. The zero value gives the
+// same behaviour as Parse.
+type ParseOptions struct {
+	// Encoding is the character encoding of the input, e.g.
+	// charmap.ISO8859_1 or charmap.Windows1252 from
+	// golang.org/x/text/encoding/charmap. The input is transcoded to UTF-8
+	// before lexing. If the input starts with a UTF-8 or UTF-16 byte order
+	// mark, the encoding of the mark is used instead. Default (nil) is UTF-8.
+	Encoding encoding.Encoding
+
+	// AllowUndefinedStringVars resolves references to undefined string
+	// variables to the empty string (as BibTeX does, with a warning) instead
+	// of failing. String variables are checked at the end of parsing, so a
+	// variable may be used before it is defined in either case. The
+	// references are reported as warnings wrapping ErrUnknownStringVar.
+	AllowUndefinedStringVars bool
+
+	// OnWarning is called with each non-fatal problem found while parsing,
+	// as a ParseError (see ParseResult). Default (nil) ignores warnings.
+	OnWarning func(error)
+
+	// Strict fails parsing if there are any warnings, returning them as
+	// errors. Default false.
+	Strict bool
+
+	// AuthorSeparator separates the names in author and editor fields of the
+	// input, e.g. ";" for "Knuth, Donald; Lamport, Leslie" as written by some
+	// export tools. The fields are rewritten to the standard " and "
+	// separator after parsing (see NormalizeAuthorFields). Default (empty) is
+	// " and ", which leaves the fields as they are.
+	AuthorSeparator string
+}
+
+// ParseResult is the result of ParseWithWarnings: the parsed bibliography and
+// the non-fatal problems found, in order. Warnings wrap one of
+//
+//   - ErrDuplicateCiteKey for an entry with the cite name (compared
+//     case-insensitively) of an earlier entry, which is kept nonetheless;
+//   - ErrUnknownStringVar for a reference to an undefined string variable,
+//     if AllowUndefinedStringVars is set;
+//   - ErrStringVarRedefined for a @string redefining a variable, which
+//     replaces the earlier definition as in BibTeX;
+//   - ErrEmptyField for a field with an empty (or only whitespace) value.
+type ParseResult struct {
+	Bib      *BibTex
+	Warnings []ParseError
+}
+
+// ParseWithOptions is like Parse but with the given options.
+func ParseWithOptions(r io.Reader, opts ParseOptions) (*BibTex, error) {
+	res, err := ParseWithWarnings(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	return res.Bib, nil
+}
+
+// ParseWithWarnings is like ParseWithOptions but also returns the warnings
+// found while parsing. In Strict mode, warnings are returned (joined) as the
+// error instead.
+func ParseWithWarnings(r io.Reader, opts ParseOptions) (*ParseResult, error) {
+	if opts.Encoding != nil {
+		r = transform.NewReader(r, unicode.BOMOverride(opts.Encoding.NewDecoder()))
+	}
+	l := NewLexer(r)
+	l.allowUndefinedSV = opts.AllowUndefinedStringVars
+	l.onWarning = opts.OnWarning
+	bib, err := parse(l)
+	if err != nil {
+		return nil, err
+	}
+	if opts.AuthorSeparator != "" {
+		bib.NormalizeAuthorFields(opts)
+	}
+	if opts.Strict && len(l.warnings) > 0 {
+		errs := make([]error, len(l.warnings))
+		for i, w := range l.warnings {
+			errs[i] = w
+		}
+		return nil, errors.Join(errs...)
+	}
+	return &ParseResult{Bib: bib, Warnings: l.warnings}, nil
+}
@@ -0,0 +1,123 @@
+package bibtex
+
+import (
+	"strings"
+)
+
+// Transform is a step of a Pipeline that modifies a bibliography in place.
+type Transform func(*BibTex) error
+
+// Pipeline is a sequence of transforms, e.g.
+//
+//	bibtex.Pipeline{bibtex.NormalizeWhitespace, bibtex.NormalizePages}.Run(bib)
+type Pipeline []Transform
+
+// Run applies the transforms of the pipeline to bib in order, and stops at
+// the first error, which is returned.
+func (p Pipeline) Run(bib *BibTex) error {
+	for _, transform := range p {
+		if err := transform(bib); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply runs the transforms on bib as a Pipeline.
+func (bib *BibTex) Apply(transforms ...Transform) error {
+	return Pipeline(transforms).Run(bib)
+}
+
+// NormalizeWhitespace replaces each run of whitespace in the field values of
+// all entries with a single space, and trims values that are constants.
+// Verbatim fields such as url and doi are left unchanged.
+func NormalizeWhitespace(bib *BibTex) error {
+	collapse := func(s string) string {
+		var buf strings.Builder
+		space := false
+		for _, r := range s {
+			if isWhitespace(r) {
+				space = true
+				continue
+			}
+			if space {
+				buf.WriteByte(' ')
+				space = false
+			}
+			buf.WriteRune(r)
+		}
+		if space {
+			buf.WriteByte(' ')
+		}
+		return buf.String()
+	}
+	for _, entry := range bib.Entries {
+		for name, val := range entry.Fields {
+			if isVerbatimField(name) {
+				continue
+			}
+			val = mapConstants(val, collapse)
+			if c, ok := val.(BibConst); ok {
+				val = BibConst(strings.TrimSpace(string(c)))
+			}
+			entry.Fields[name] = val
+		}
+	}
+	return nil
+}
+
+// NormalizePages normalises the page ranges of all entries (see
+// BibEntry.NormalizePageRange).
+func NormalizePages(bib *BibTex) error {
+	for _, entry := range bib.Entries {
+		entry.NormalizePageRange()
+	}
+	return nil
+}
+
+// NormalizeDOIs removes URL and doi: prefixes from the doi field of all
+// entries, e.g. https://doi.org/10.1000/xyz becomes 10.1000/xyz.
+func NormalizeDOIs(bib *BibTex) error {
+	for _, entry := range bib.Entries {
+		for name, val := range entry.Fields {
+			if strings.EqualFold(name, "doi") {
+				entry.Fields[name] = NewBibConst(trimDOIPrefix(inlineString(val)))
+			}
+		}
+	}
+	return nil
+}
+
+// NormalizeURLs cleans up the url field of all entries: surrounding
+// whitespace and angle brackets, as in <https://example.com>, are removed and
+// spaces are percent-encoded. Fields that need no change are left as they are.
+func NormalizeURLs(bib *BibTex) error {
+	for _, entry := range bib.Entries {
+		for name, val := range entry.Fields {
+			if !strings.EqualFold(name, "url") {
+				continue
+			}
+			if url := normalizeURL(inlineString(val)); url != inlineString(val) {
+				entry.Fields[name] = NewBibConst(url)
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeURL returns url without surrounding whitespace and angle brackets,
+// and with spaces percent-encoded.
+func normalizeURL(url string) string {
+	url = strings.TrimSpace(url)
+	if strings.HasPrefix(url, "<") && strings.HasSuffix(url, ">") {
+		url = strings.TrimSpace(url[1 : len(url)-1])
+	}
+	return strings.ReplaceAll(url, " ", "%20")
+}
+
+// RemoveEmptyFields removes the empty fields of all entries (see
+// BibTex.RemoveEmptyFields).
+func RemoveEmptyFields(bib *BibTex) error {
+	bib.RemoveEmptyFields()
+	return nil
+}
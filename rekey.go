@@ -0,0 +1,55 @@
+package bibtex
+
+import (
+	"strings"
+)
+
+// keyFields are the fields whose values are cite names of other entries, as
+// lists separated by commas.
+var keyFields = []string{"crossref", "xref", "entryset"}
+
+// Rekey renames the entries to the cite names returned by fn, which is called
+// for each entry in order with the set of new cite names given so far (to
+// avoid duplicates). If fn returns an empty string, the entry keeps its cite
+// name. References to renamed entries in crossref, xref and entryset fields
+// are updated (matching the old cite names case-insensitively). No other
+// field is changed.
+func (bib *BibTex) Rekey(fn func(*BibEntry, map[string]bool) string) {
+	taken := make(map[string]bool, len(bib.Entries))
+	renamed := make(map[string]string) // Old cite key to new cite name.
+	for _, entry := range bib.Entries {
+		name := fn(entry, taken)
+		if name == "" {
+			name = entry.CiteName
+		}
+		if _, seen := renamed[citeKey(entry.CiteName)]; !seen && name != entry.CiteName {
+			renamed[citeKey(entry.CiteName)] = name
+		}
+		entry.CiteName = name
+		taken[name] = true
+	}
+	bib.index = nil
+	if len(renamed) == 0 {
+		return
+	}
+	for _, entry := range bib.Entries {
+		for _, field := range keyFields {
+			val, ok := entry.Fields[field]
+			if !ok {
+				continue
+			}
+			keys := strings.Split(val.String(), ",")
+			changed := false
+			for i, key := range keys {
+				if name, ok := renamed[citeKey(strings.TrimSpace(key))]; ok {
+					keys[i], changed = name, true
+				} else {
+					keys[i] = strings.TrimSpace(key)
+				}
+			}
+			if changed {
+				entry.AddField(field, NewBibConst(strings.Join(keys, ",")))
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package bibtex
+
+import "sync"
+
+// SafeBibTex wraps a BibTex so that entries, string variables and preambles
+// can be added from multiple goroutines.
+//
+// Only the methods of SafeBibTex are guarded. The wrapped BibTex should not be
+// accessed directly until all goroutines adding to it have finished.
+type SafeBibTex struct {
+	mu  sync.Mutex
+	bib *BibTex
+}
+
+// NewSafeBibTex creates a new SafeBibTex wrapping bib. If bib is nil, a new
+// BibTex is created.
+func NewSafeBibTex(bib *BibTex) *SafeBibTex {
+	if bib == nil {
+		bib = NewBibTex()
+	}
+	return &SafeBibTex{bib: bib}
+}
+
+// AddEntry adds an entry to the BibTeX data structure.
+func (s *SafeBibTex) AddEntry(entry *BibEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bib.AddEntry(entry)
+}
+
+// AddStringVar adds a new string var.
+func (s *SafeBibTex) AddStringVar(key string, val BibString) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bib.AddStringVar(key, val)
+}
+
+// AddPreamble adds a preamble to a bibtex.
+func (s *SafeBibTex) AddPreamble(p BibString) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bib.AddPreamble(p)
+}
+
+// GetStringVar looks up a string by its key.
+func (s *SafeBibTex) GetStringVar(key string) (*BibVar, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bib.GetStringVar(key)
+}
+
+// Len returns the number of entries.
+func (s *SafeBibTex) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bib.Entries)
+}
+
+// Do calls fn with the wrapped BibTex while holding the lock, for operations
+// not covered by the other methods.
+func (s *SafeBibTex) Do(fn func(bib *BibTex)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.bib)
+}
+
+// BibTex returns the wrapped BibTex.
+func (s *SafeBibTex) BibTex() *BibTex {
+	return s.bib
+}
@@ -3,18 +3,22 @@ package bibtex
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"io"
-	"log"
 	"strconv"
 	"strings"
 )
 
-var parseField bool
-
 // Scanner is a lexical scanner
 type Scanner struct {
-	r   *bufio.Reader
-	pos TokenPos
+	r          *bufio.Reader
+	pos        TokenPos
+	parseField bool     // Whether the scanner is inside a field value.
+	err        error    // Error found while scanning the last token.
+	started    bool     // Whether the start of input (and BOM) has been read.
+	last       Token    // The last token scanned.
+	start      TokenPos // Start of the token being scanned.
+	comment    bool     // Whether the last tokens were @comment.
 }
 
 // NewScanner returns a new instance of Scanner.
@@ -49,42 +53,70 @@ func (s *Scanner) unread() {
 	}
 }
 
-// Scan returns the next token and literal value.
-func (s *Scanner) Scan() (tok Token, lit string) {
+// Scan returns the next token, its literal value and the position of the
+// first character of the token. At the end of the input, Scan returns EOF.
+//
+// The literal value of IDENT tokens is the content of a quoted or braced
+// string without its delimiters, or a number. See Token for the token kinds.
+func (s *Scanner) Scan() (tok Token, lit string, pos TokenPos) {
+	if !s.started {
+		s.started = true
+		s.skipBOM()
+	}
+	s.ignoreWhitespace()
+	pos = TokenPos{Char: s.pos.Char + 1, Lines: s.pos.Lines}
+	s.start = pos
+	tok, lit = s.scan()
+	s.comment = s.last == ATSIGN && tok == COMMENT
+	s.last = tok
+	return tok, lit, pos
+}
+
+// scan returns the next token and literal value.
+func (s *Scanner) scan() (tok Token, lit string) {
 	ch := s.read()
 	if isWhitespace(ch) {
 		s.ignoreWhitespace()
 		ch = s.read()
 	}
-	if isAlphanum(ch) {
+	if isAlphanum(ch) || isBareSymbol(ch) {
 		s.unread()
 		return s.scanIdent()
 	}
 	switch ch {
 	case eof:
-		return 0, ""
+		return EOF, ""
 	case '@':
+		s.parseField = false // an @ outside braces always starts a new entry.
 		return ATSIGN, string(ch)
-	case ':':
-		return COLON, string(ch)
 	case ',':
-		parseField = false // reset parseField if reached end of field.
+		s.parseField = false // reset parseField if reached end of field.
 		return COMMA, string(ch)
 	case '=':
-		parseField = true // set parseField if = sign outside quoted or ident.
+		s.parseField = true // set parseField if = sign outside quoted or ident.
 		return EQUAL, string(ch)
 	case '"':
 		return s.scanQuoted()
 	case '{':
-		if parseField {
+		if s.comment {
+			return s.scanComment('{', '}')
+		}
+		if s.parseField {
 			return s.scanBraced()
 		}
+		if s.last == PREAMBLE { // preamble content is a value like a field.
+			s.parseField = true
+		}
 		return LBRACE, string(ch)
 	case '}':
-		if parseField { // reset parseField if reached end of entry.
-			parseField = false
+		if s.parseField { // reset parseField if reached end of entry.
+			s.parseField = false
 		}
 		return RBRACE, string(ch)
+	case '(':
+		if s.comment {
+			return s.scanComment('(', ')')
+		}
 	case '#':
 		return POUND, string(ch)
 	case ' ':
@@ -125,19 +157,34 @@ func (s *Scanner) scanBare() (Token, string) {
 		return PREAMBLE, str
 	} else if strings.ToLower(str) == "string" {
 		return STRING, str
-	} else if _, err := strconv.Atoi(str); err == nil && parseField { // Special case for numeric
+	} else if _, err := strconv.Atoi(str); err == nil && s.parseField { // Special case for numeric
 		return IDENT, str
 	}
 	return BAREIDENT, str
 }
 
-// scanBraced parses a braced string, like {this}.
+// unterminated records an error for a value started but not terminated
+// before the end of input or the start of the next entry.
+func (s *Scanner) unterminated() (Token, string) {
+	s.err = fmt.Errorf("%w starting at line %d", ErrUnterminatedValue, s.start.Line())
+	return ILLEGAL, ""
+}
+
+// scanBraced parses a braced string, like {this}. An @ at the start of a line
+// is taken as the start of the next entry, so the value is unterminated.
 func (s *Scanner) scanBraced() (Token, string) {
 	var buf bytes.Buffer
 	var macro bool
 	brace := 1
+	lineStart := false // Whether only whitespace was read since a newline.
 	for {
-		if ch := s.read(); ch == eof {
+		ch := s.read()
+		if ch == '@' && lineStart {
+			s.unread()
+			return s.unterminated()
+		}
+		lineStart = ch == '\n' || lineStart && isWhitespace(ch)
+		if ch == eof {
 			break
 		} else if ch == '\\' {
 			_, _ = buf.WriteRune(ch)
@@ -156,7 +203,9 @@ func (s *Scanner) scanBraced() (Token, string) {
 			if macro {
 				_, _ = buf.WriteRune(ch)
 			} else {
-				log.Fatalf("%s: %s", ErrUnexpectedAtsign, buf.String())
+				s.unread() // Leave @ for the next entry.
+				s.err = fmt.Errorf("%w: %s", ErrUnexpectedAtsign, buf.String())
+				return ILLEGAL, buf.String()
 			}
 		} else if isWhitespace(ch) {
 			_, _ = buf.WriteRune(ch)
@@ -165,15 +214,44 @@ func (s *Scanner) scanBraced() (Token, string) {
 			_, _ = buf.WriteRune(ch)
 		}
 	}
-	return ILLEGAL, buf.String()
+	return s.unterminated()
+}
+
+// scanComment parses the content of a @comment delimited by left and right,
+// like {any @ text} or (any @ text), as opaque text: only the delimiters are
+// balanced.
+func (s *Scanner) scanComment(left, right rune) (Token, string) {
+	var buf bytes.Buffer
+	depth := 1
+	for {
+		ch := s.read()
+		if ch == eof {
+			return s.unterminated()
+		} else if ch == left {
+			depth++
+		} else if ch == right {
+			if depth--; depth == 0 {
+				return IDENT, buf.String()
+			}
+		}
+		_, _ = buf.WriteRune(ch)
+	}
 }
 
-// scanQuoted parses a quoted string, like "this".
+// scanQuoted parses a quoted string, like "this". An @ at the start of a line
+// is taken as the start of the next entry, so the value is unterminated.
 func (s *Scanner) scanQuoted() (Token, string) {
 	var buf bytes.Buffer
 	brace := 0
+	lineStart := false // Whether only whitespace was read since a newline.
 	for {
-		if ch := s.read(); ch == eof {
+		ch := s.read()
+		if ch == '@' && lineStart {
+			s.unread()
+			return s.unterminated()
+		}
+		lineStart = ch == '\n' || lineStart && isWhitespace(ch)
+		if ch == eof {
 			break
 		} else if ch == '{' {
 			brace++
@@ -188,17 +266,37 @@ func (s *Scanner) scanQuoted() (Token, string) {
 			_, _ = buf.WriteRune(ch)
 		}
 	}
-	return ILLEGAL, buf.String()
+	return s.unterminated()
+}
+
+// skipBOM consumes a UTF-8 byte order mark at the start of the input.
+func (s *Scanner) skipBOM() {
+	if ch, _, err := s.r.ReadRune(); err == nil && ch != '\uFEFF' {
+		_ = s.r.UnreadRune()
+	}
 }
 
 // ignoreWhitespace consumes the current rune and all contiguous whitespace.
+// Comments from % to the end of the line count as whitespace (except in
+// values, where % is kept).
 func (s *Scanner) ignoreWhitespace() {
 	for {
 		if ch := s.read(); ch == eof {
 			break
+		} else if ch == '%' {
+			s.ignoreComment()
 		} else if !isWhitespace(ch) {
 			s.unread()
 			break
 		}
 	}
 }
+
+// ignoreComment consumes the rest of the line.
+func (s *Scanner) ignoreComment() {
+	for {
+		if ch := s.read(); ch == eof || ch == '\n' {
+			break
+		}
+	}
+}
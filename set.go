@@ -0,0 +1,45 @@
+package bibtex
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SetMembers returns the member entries of the biblatex @set entry with the
+// cite name key (matched case-insensitively), in the order of its entryset
+// field. It returns an error wrapping ErrUnknownCiteKey if the set or any of
+// its members does not exist, and ErrMissingField if the entry has no entryset
+// field.
+func (bib *BibTex) SetMembers(key string) ([]*BibEntry, error) {
+	entries := make(map[string]*BibEntry, len(bib.Entries))
+	for _, entry := range bib.Entries {
+		if _, exists := entries[citeKey(entry.CiteName)]; !exists {
+			entries[citeKey(entry.CiteName)] = entry
+		}
+	}
+	set, ok := entries[citeKey(key)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCiteKey, key)
+	}
+	entryset, ok := set.Fields["entryset"]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s: entryset", ErrMissingField, key)
+	}
+	var members []*BibEntry
+	var errs []error
+	for _, member := range strings.Split(entryset.String(), ",") {
+		if member = strings.TrimSpace(member); member == "" {
+			continue
+		}
+		if entry, ok := entries[citeKey(member)]; ok {
+			members = append(members, entry)
+		} else {
+			errs = append(errs, fmt.Errorf("%w: %s: member %s", ErrUnknownCiteKey, key, member))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return members, nil
+}
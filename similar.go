@@ -0,0 +1,128 @@
+package bibtex
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SimilarityFunc returns the similarity of two normalised titles, from 0
+// (different) to 1 (equal).
+type SimilarityFunc func(a, b string) float64
+
+// TokenJaccard is the Jaccard index of the sets of words of a and b, i.e. the
+// number of words in both divided by the number of words in either.
+func TokenJaccard(a, b string) float64 {
+	words := make(map[string]int)
+	for _, w := range strings.Fields(a) {
+		words[w] |= 1
+	}
+	for _, w := range strings.Fields(b) {
+		words[w] |= 2
+	}
+	if len(words) == 0 {
+		return 1
+	}
+	both := 0
+	for _, in := range words {
+		if in == 3 {
+			both++
+		}
+	}
+	return float64(both) / float64(len(words))
+}
+
+// LevenshteinRatio is 1 minus the edit distance (in characters) of a and b
+// divided by the length of the longer string.
+func LevenshteinRatio(a, b string) float64 {
+	s, t := []rune(a), []rune(b)
+	longest := len(s)
+	if len(t) > longest {
+		longest = len(t)
+	}
+	if longest == 0 {
+		return 1
+	}
+	prev := make([]int, len(t)+1)
+	cur := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		cur[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return 1 - float64(prev[len(t)])/float64(longest)
+}
+
+// normalizedTitle returns the title of entry lower-cased, with LaTeX markup
+// converted and punctuation replaced by single spaces, or "" if the entry has
+// no title.
+func normalizedTitle(entry *BibEntry) string {
+	title, ok := entry.DisplayField("title")
+	if !ok {
+		return ""
+	}
+	notWord := func(r rune) bool { return !unicode.In(r, unicode.L, unicode.M, unicode.N) }
+	return strings.Join(strings.FieldsFunc(strings.ToLower(LaTeXToUnicode(title)), notWord), " ")
+}
+
+// FindSimilar returns the groups of entries with similar titles, as
+// FindSimilarFunc with TokenJaccard.
+func (bib *BibTex) FindSimilar(threshold float64) [][]*BibEntry {
+	return bib.FindSimilarFunc(threshold, TokenJaccard)
+}
+
+// FindSimilarFunc returns the groups of entries (possible duplicates) whose
+// normalised titles (lower-cased, without LaTeX markup and punctuation) have a
+// similarity of at least threshold, e.g. 0.8. An entry is in a group if it is
+// similar to any entry of the group. Groups and the entries in each group are
+// in document order, and entries without title or similar entries are left
+// out.
+func (bib *BibTex) FindSimilarFunc(threshold float64, similarity SimilarityFunc) [][]*BibEntry {
+	titles := make([]string, len(bib.Entries))
+	group := make([]int, len(bib.Entries)) // Index of the first entry of the group.
+	for i, entry := range bib.Entries {
+		titles[i], group[i] = normalizedTitle(entry), i
+	}
+	root := func(i int) int {
+		for group[i] != i {
+			i = group[i]
+		}
+		return i
+	}
+	for i := range titles {
+		for j := i + 1; j < len(titles); j++ {
+			if titles[i] == "" || titles[j] == "" || similarity(titles[i], titles[j]) < threshold {
+				continue
+			}
+			if ri, rj := root(i), root(j); ri < rj {
+				group[rj] = ri
+			} else if rj < ri {
+				group[ri] = rj
+			}
+		}
+	}
+	members := make(map[int][]*BibEntry)
+	var roots []int
+	for i, entry := range bib.Entries {
+		r := root(i)
+		if members[r] == nil {
+			roots = append(roots, r)
+		}
+		members[r] = append(members[r], entry)
+	}
+	var groups [][]*BibEntry
+	for _, r := range roots {
+		if len(members[r]) > 1 {
+			groups = append(groups, members[r])
+		}
+	}
+	return groups
+}
@@ -0,0 +1,119 @@
+package bibtex
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SortByYear sorts the entries by their year, in ascending or descending
+// order. Entries without a numeric year are treated as earlier than any year,
+// so they come first in ascending order and last in descending order, the
+// usual order of publication lists. Unlike SortByAuthor, which puts missing
+// authors last in either order, a missing year thus follows the direction of
+// the sort. The sort is stable.
+func (bib *BibTex) SortByYear(ascending bool) {
+	years := make(map[*BibEntry]int, len(bib.Entries))
+	for _, entry := range bib.Entries {
+		if year, err := entry.GetYear(); err == nil {
+			years[entry] = year
+		}
+	}
+	later := func(a, b *BibEntry) bool { // a is strictly later than b.
+		ya, aok := years[a]
+		yb, bok := years[b]
+		switch {
+		case !aok:
+			return false
+		case !bok:
+			return true
+		}
+		return ya > yb
+	}
+	sort.SliceStable(bib.Entries, func(i, j int) bool {
+		if ascending {
+			return later(bib.Entries[j], bib.Entries[i])
+		}
+		return later(bib.Entries[i], bib.Entries[j])
+	})
+	bib.index = nil
+}
+
+// SortByAuthor sorts the entries by the family name of their first author
+// (then by the first names), in ascending or descending order. Entries with a
+// missing or unparseable author field come last in either order, and the
+// errors parsing author fields are returned (joined) after sorting. The sort
+// is stable.
+func (bib *BibTex) SortByAuthor(ascending bool) error {
+	var errs []error
+	names := make(map[*BibEntry]string, len(bib.Entries))
+	for _, entry := range bib.Entries {
+		author, ok := entry.field("author")
+		if !ok {
+			continue
+		}
+		authors, err := ParseAuthors(inlineString(author))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("author in %s: %w", entry.CiteName, err))
+			continue
+		}
+		if len(authors) > 0 && authors[0].Last != "" {
+			names[entry] = strings.ToLower(LaTeXToUnicode(authors[0].Last) + "\x00" + LaTeXToUnicode(authors[0].First))
+		}
+	}
+	sort.SliceStable(bib.Entries, func(i, j int) bool {
+		a, aok := names[bib.Entries[i]]
+		b, bok := names[bib.Entries[j]]
+		switch {
+		case !aok || !bok:
+			return aok && !bok
+		case ascending:
+			return a < b
+		}
+		return a > b
+	})
+	bib.index = nil
+	return errors.Join(errs...)
+}
+
+// DefaultFieldOrder is a conventional order of fields for SortFieldsCanonical
+// and Encoder.FieldOrder.
+var DefaultFieldOrder = []string{
+	"author", "editor", "title", "subtitle", "journal", "booktitle", "series",
+	"edition", "year", "month", "volume", "number", "chapter", "pages",
+	"publisher", "organization", "institution", "school", "address", "isbn",
+	"issn", "doi", "url", "urldate", "note", "keywords", "abstract",
+}
+
+// canonicalFieldOrder returns the field names of entry that are in order in
+// that order, followed by the other field names in alphabetical order.
+func canonicalFieldOrder(entry *BibEntry, order []string) []string {
+	names := make([]string, 0, len(entry.Fields))
+	seen := make(map[string]bool, len(entry.Fields))
+	for _, name := range order {
+		if _, ok := entry.Fields[name]; ok && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, name := range entry.FieldNames() {
+		if !seen[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// SortFieldsCanonical reorders the fields of the entry by DefaultFieldOrder,
+// followed by any other fields in alphabetical order.
+func (entry *BibEntry) SortFieldsCanonical() {
+	entry.order = canonicalFieldOrder(entry, DefaultFieldOrder)
+}
+
+// SortFieldsCanonical reorders the fields of all entries by DefaultFieldOrder.
+func (bib *BibTex) SortFieldsCanonical() {
+	for _, entry := range bib.Entries {
+		entry.SortFieldsCanonical()
+	}
+}
@@ -3,15 +3,59 @@ package bibtex
 import (
 	"fmt"
 	"strings"
+	"unicode"
 )
 
-// Lexer token.
+// Token is a lexer token returned by Scanner.
+//
+// The token kinds are:
+//
+//	EOF        end of input
+//	ILLEGAL    invalid input, e.g. an unterminated string
+//	ATSIGN     @
+//	LBRACE     { opening an entry
+//	RBRACE     } closing an entry
+//	EQUAL      =
+//	COMMA      ,
+//	POUND      # (string concatenation)
+//	COMMENT    the keyword comment (case-insensitive)
+//	STRING     the keyword string (case-insensitive)
+//	PREAMBLE   the keyword preamble (case-insensitive)
+//	BAREIDENT  an unquoted word, e.g. an entry type, cite key, field name or
+//	           string variable
+//	IDENT      a "quoted" or {braced} string, or a number in a field value
+//
+// The token values of all kinds except EOF are generated by goyacc.
 type Token int
 
-const (
-	// ILLEGAL stands for an invalid token.
-	ILLEGAL Token = iota
-)
+// EOF is the token returned at the end of input.
+const EOF Token = 0
+
+var tokenNames = map[Token]string{
+	EOF:       "EOF",
+	ILLEGAL:   "ILLEGAL",
+	ATSIGN:    "ATSIGN",
+	LBRACE:    "LBRACE",
+	RBRACE:    "RBRACE",
+	EQUAL:     "EQUAL",
+	COMMA:     "COMMA",
+	POUND:     "POUND",
+	COMMENT:   "COMMENT",
+	STRING:    "STRING",
+	PREAMBLE:  "PREAMBLE",
+	BAREIDENT: "BAREIDENT",
+	IDENT:     "IDENT",
+	DQUOTE:    "DQUOTE",
+	LPAREN:    "LPAREN",
+	RPAREN:    "RPAREN",
+}
+
+func (t Token) String() string {
+	if name, ok := tokenNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("Token(%d)", int(t))
+}
 
 var eof = rune(0)
 
@@ -21,6 +65,16 @@ type TokenPos struct {
 	Lines []int
 }
 
+// Line returns the line number (starting from 1).
+func (p TokenPos) Line() int {
+	return len(p.Lines) + 1
+}
+
+// Column returns the column number (starting from 1 for the first character).
+func (p TokenPos) Column() int {
+	return p.Char
+}
+
 func (p TokenPos) String() string {
 	return fmt.Sprintf("%d:%d", len(p.Lines)+1, p.Char)
 }
@@ -30,7 +84,7 @@ func isWhitespace(ch rune) bool {
 }
 
 func isAlpha(ch rune) bool {
-	return ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z')
+	return unicode.IsLetter(ch)
 }
 
 func isDigit(ch rune) bool {
@@ -41,8 +95,13 @@ func isAlphanum(ch rune) bool {
 	return isAlpha(ch) || isDigit(ch)
 }
 
+// bareSymbols are the symbols allowed in bare identifiers (entry types, cite
+// keys, field names and string variables) besides letters and digits.
+// Whitespace and the characters "#%'(),={}@\ are not allowed.
+const bareSymbols = "!$&*+-./:;<>?[]^_`|~"
+
 func isBareSymbol(ch rune) bool {
-	return strings.ContainsRune("-_:./+", ch)
+	return strings.ContainsRune(bareSymbols, ch)
 }
 
 // isSymbol returns true if ch is a valid symbol
@@ -0,0 +1,148 @@
+package bibtex
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidationError is a field of an entry that exceeds its length limit.
+type ValidationError struct {
+	CiteName string // Cite name of the entry.
+	Field    string // Name of the field.
+	Limit    int    // Maximum length of the field.
+	Length   int    // Actual length of the field.
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: field %s is %d characters long (limit %d)", e.CiteName, e.Field, e.Length, e.Limit)
+}
+
+// ValidateFieldLengths checks the length (in characters, with string
+// variables resolved) of the fields named in limits, and returns an error for
+// each field longer than its limit, ordered by field name.
+func (entry *BibEntry) ValidateFieldLengths(limits map[string]int) []ValidationError {
+	var errs []ValidationError
+	for field, limit := range limits {
+		val, ok := entry.Fields[field]
+		if !ok {
+			continue
+		}
+		if length := utf8.RuneCountInString(val.String()); length > limit {
+			errs = append(errs, ValidationError{CiteName: entry.CiteName, Field: field, Limit: limit, Length: length})
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return errs
+}
+
+// requiredFields are the fields required by the standard BibTeX styles (see
+// btxdoc) and types added with RegisterEntryType, by entry type. Each element
+// is a group of alternatives, one of which is required, e.g. author or editor.
+var requiredFields = map[string][][]string{
+	"article":       {{"author"}, {"title"}, {"journal"}, {"year"}},
+	"book":          {{"author", "editor"}, {"title"}, {"publisher"}, {"year"}},
+	"booklet":       {{"title"}},
+	"conference":    {{"author"}, {"title"}, {"booktitle"}, {"year"}},
+	"inbook":        {{"author", "editor"}, {"title"}, {"chapter", "pages"}, {"publisher"}, {"year"}},
+	"incollection":  {{"author"}, {"title"}, {"booktitle"}, {"publisher"}, {"year"}},
+	"inproceedings": {{"author"}, {"title"}, {"booktitle"}, {"year"}},
+	"manual":        {{"title"}},
+	"mastersthesis": {{"author"}, {"title"}, {"school"}, {"year"}},
+	"misc":          {},
+	"phdthesis":     {{"author"}, {"title"}, {"school"}, {"year"}},
+	"proceedings":   {{"title"}, {"year"}},
+	"techreport":    {{"author"}, {"title"}, {"institution"}, {"year"}},
+	"unpublished":   {{"author"}, {"title"}, {"note"}},
+}
+
+// strictRequiredFields are the fields that some styles treat as required in
+// addition to requiredFields, by entry type.
+var strictRequiredFields = map[string][][]string{
+	"article":       {{"volume"}, {"pages"}},
+	"book":          {{"address"}},
+	"conference":    {{"pages"}, {"address"}},
+	"inbook":        {{"address"}},
+	"incollection":  {{"pages"}, {"address"}},
+	"inproceedings": {{"pages"}, {"address"}},
+	"mastersthesis": {{"address"}},
+	"phdthesis":     {{"address"}},
+	"techreport":    {{"number"}},
+}
+
+// RegisterEntryType sets the fields required for entries of type typ
+// (compared case-insensitively) by RequiredFields and Validate, replacing
+// those of a standard or already registered type. Each element of required is
+// an OR-group: one of its fields is required, and all groups must be
+// satisfied. For example, the biblatex online type
+//
+//	bibtex.RegisterEntryType("online", [][]string{{"author", "editor"}, {"title"}, {"url"}, {"urldate"}})
+//
+// requires author or editor, and title, url and urldate. Types are shared by
+// all entries, so they should be registered before validating, e.g. in an init
+// function.
+func RegisterEntryType(typ string, required [][]string) {
+	groups := make([][]string, len(required))
+	for i, group := range required {
+		groups[i] = append([]string(nil), group...)
+	}
+	requiredFields[strings.ToLower(typ)] = groups
+}
+
+// RequiredFields returns the fields required for the type of the entry by the
+// standard BibTeX styles (or RegisterEntryType), in conventional order.
+// Alternatives are joined by "/", e.g. "author/editor" for a book, meaning that
+// one of them is required. If strict is true, fields that some styles also
+// treat as required (e.g. address for inproceedings) are included. It returns
+// nil for types that are neither standard BibTeX types nor registered.
+func (entry *BibEntry) RequiredFields(strict bool) []string {
+	t := strings.ToLower(entry.Type)
+	groups, ok := requiredFields[t]
+	if !ok {
+		return nil
+	}
+	if strict {
+		groups = append(groups[:len(groups):len(groups)], strictRequiredFields[t]...)
+	}
+	fields := make([]string, len(groups))
+	for i, group := range groups {
+		fields[i] = strings.Join(group, "/")
+	}
+	return fields
+}
+
+// Validate checks that the entry has the fields required for its type (see
+// RequiredFields), i.e. at least one field with a non-empty value of each
+// OR-group. It returns an error wrapping ErrMissingField for each group
+// without such a field, joined, or nil if there is none or the type is not
+// known.
+func (entry *BibEntry) Validate() error {
+	var errs []error
+	for _, group := range requiredFields[strings.ToLower(entry.Type)] {
+		found := len(group) == 0
+		for _, name := range group {
+			if val, ok := entry.field(name); ok && strings.TrimSpace(inlineString(val)) != "" {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, fmt.Errorf("%w: %s in %s", ErrMissingField, strings.Join(group, "/"), entry.CiteName))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks the required fields of all entries (see BibEntry.Validate),
+// and returns the errors of all entries in order, joined.
+func (bib *BibTex) Validate() error {
+	var errs []error
+	for _, entry := range bib.Entries {
+		if err := entry.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
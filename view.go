@@ -0,0 +1,119 @@
+package bibtex
+
+import (
+	"sort"
+	"strings"
+)
+
+// venueFields are the fields naming where an entry was published, in order of
+// preference.
+var venueFields = []string{"journal", "booktitle", "series", "publisher", "school", "institution", "organization", "howpublished"}
+
+// EntryView is a read-only view of an entry for templates (e.g. html/template),
+// with values converted from LaTeX to plain Unicode text (see LaTeXToUnicode),
+// except for VerbatimFields. Math is kept with its delimiters. It is a copy, so later changes to the entry
+// do not affect it.
+type EntryView struct {
+	key, typ string
+	fields   map[string]string
+	authors  []Author
+}
+
+// NewEntryView creates a view of entry.
+func NewEntryView(entry *BibEntry) EntryView {
+	return newEntryView(entry, false)
+}
+
+// newEntryView creates a view of entry, removing the delimiters of math if
+// stripMath is set.
+func newEntryView(entry *BibEntry, stripMath bool) EntryView {
+	v := EntryView{key: entry.CiteName, typ: entry.Type, fields: make(map[string]string, len(entry.Fields))}
+	for name, val := range entry.Fields {
+		if isVerbatimField(name) {
+			v.fields[strings.ToLower(name)] = strings.TrimSpace(inlineString(val))
+			continue
+		}
+		v.fields[strings.ToLower(name)] = strings.TrimSpace(latexToUnicode(inlineString(val), stripMath))
+	}
+	if author, ok := entry.field("author"); ok {
+		if authors, err := ParseAuthors(inlineString(author)); err == nil {
+			for _, a := range authors {
+				v.authors = append(v.authors, Author{
+					First: latexToUnicode(a.First, stripMath),
+					Von:   latexToUnicode(a.Von, stripMath),
+					Last:  latexToUnicode(a.Last, stripMath),
+					Jr:    latexToUnicode(a.Jr, stripMath),
+				})
+			}
+		}
+	}
+	return v
+}
+
+// Key returns the cite name of the entry.
+func (v EntryView) Key() string { return v.key }
+
+// Type returns the type of the entry, e.g. article.
+func (v EntryView) Type() string { return v.typ }
+
+// Field returns the field name, or an empty string if the entry does not have
+// it.
+func (v EntryView) Field(name string) string { return v.fields[strings.ToLower(name)] }
+
+// Title returns the title of the entry.
+func (v EntryView) Title() string { return v.fields["title"] }
+
+// Year returns the year of the entry.
+func (v EntryView) Year() string { return v.fields["year"] }
+
+// AuthorsFormatted returns the authors of the entry in full, e.g. "Donald E.
+// Knuth and Leslie Lamport" (see FormatAuthors).
+func (v EntryView) AuthorsFormatted() string {
+	if v.authors == nil {
+		return v.fields["author"]
+	}
+	return FormatAuthors(v.authors, 0, FullName)
+}
+
+// Venue returns the journal, book or publisher the entry was published in,
+// whichever comes first.
+func (v EntryView) Venue() string {
+	for _, name := range venueFields {
+		if venue := v.fields[name]; venue != "" {
+			return venue
+		}
+	}
+	return ""
+}
+
+// DOIURL returns the https://doi.org URL of the DOI of the entry, or an empty
+// string if the entry has no DOI.
+func (v EntryView) DOIURL() string {
+	return doiURL(v.fields["doi"])
+}
+
+// URL returns the url field of the entry if it is an http, https or ftp URL
+// that can be linked to, or an empty string otherwise.
+func (v EntryView) URL() string {
+	return linkURL(v.fields["url"])
+}
+
+// sortKey returns the key views are sorted by: the last and first name of the
+// first author, the year and the title.
+func (v EntryView) sortKey() string {
+	var author string
+	if len(v.authors) > 0 {
+		author = strings.Join(nonEmpty(v.authors[0].Last, v.authors[0].First), " ")
+	}
+	return strings.ToLower(author + "\x00" + v.Year() + "\x00" + v.Title())
+}
+
+// Views returns views of all entries, sorted by first author, year and title.
+func (bib *BibTex) Views() []EntryView {
+	views := make([]EntryView, len(bib.Entries))
+	for i, entry := range bib.Entries {
+		views[i] = NewEntryView(entry)
+	}
+	sort.SliceStable(views, func(i, j int) bool { return views[i].sortKey() < views[j].sortKey() })
+	return views
+}
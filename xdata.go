@@ -0,0 +1,65 @@
+package bibtex
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ResolveXData merges the fields of the biblatex @xdata entries referenced by
+// the xdata field of each entry (a comma-separated list of cite names) into
+// the entry, and removes the xdata field. Fields of the entry take precedence,
+// followed by the @xdata entries in the order they are listed. @xdata entries
+// may themselves reference other @xdata entries.
+//
+// It returns an error wrapping ErrUnknownCiteKey for each reference to a
+// missing entry, and ErrInvalidField for each reference to an entry that is
+// not an @xdata entry or that refers back to itself. The other references are
+// still resolved.
+func (bib *BibTex) ResolveXData() error {
+	entries := make(map[string]*BibEntry, len(bib.Entries))
+	for _, entry := range bib.Entries {
+		if _, exists := entries[citeKey(entry.CiteName)]; !exists {
+			entries[citeKey(entry.CiteName)] = entry
+		}
+	}
+	var errs []error
+	resolved := make(map[*BibEntry]bool)
+	visiting := make(map[*BibEntry]bool)
+	var resolve func(entry *BibEntry)
+	resolve = func(entry *BibEntry) {
+		if resolved[entry] {
+			return
+		}
+		visiting[entry] = true
+		for name, val := range entry.Fields {
+			if !strings.EqualFold(name, "xdata") {
+				continue
+			}
+			delete(entry.Fields, name)
+			for _, key := range strings.Split(inlineString(val), ",") {
+				if key = strings.TrimSpace(key); key == "" {
+					continue
+				}
+				xdata, ok := entries[citeKey(key)]
+				switch {
+				case !ok:
+					errs = append(errs, fmt.Errorf("%w: %s: xdata %s", ErrUnknownCiteKey, entry.CiteName, key))
+				case !strings.EqualFold(xdata.Type, "xdata"):
+					errs = append(errs, fmt.Errorf("%w: xdata in %s: %s is not an @xdata entry", ErrInvalidField, entry.CiteName, key))
+				case visiting[xdata]:
+					errs = append(errs, fmt.Errorf("%w: xdata in %s: %s refers back to it", ErrInvalidField, entry.CiteName, key))
+				default:
+					resolve(xdata)
+					xdata.CopyFieldsTo(entry)
+				}
+			}
+		}
+		delete(visiting, entry)
+		resolved[entry] = true
+	}
+	for _, entry := range bib.Entries {
+		resolve(entry)
+	}
+	return errors.Join(errs...)
+}
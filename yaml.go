@@ -0,0 +1,93 @@
+//go:build yaml
+// +build yaml
+
+package bibtex
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// This file is only built with the yaml build tag, so that gopkg.in/yaml.v3
+// is not a dependency unless YAML support is needed:
+//
+//	go build -tags yaml
+
+// MarshalYAML implements yaml.Marshaler. An entry is a mapping with the type,
+// the key and a mapping of the fields (in order, with string variables
+// resolved), e.g.
+//
+//	type: article
+//	key: knuth1984
+//	fields:
+//	  title: Literate Programming
+func (entry *BibEntry) MarshalYAML() (interface{}, error) {
+	fields := &yaml.Node{Kind: yaml.MappingNode}
+	for _, name := range entry.OrderedFieldNames() {
+		fields.Content = append(fields.Content, yamlString(name), yamlString(inlineString(entry.Fields[name])))
+	}
+	return &yaml.Node{
+		Kind: yaml.MappingNode,
+		Content: []*yaml.Node{
+			yamlString("type"), yamlString(entry.Type),
+			yamlString("key"), yamlString(entry.CiteName),
+			yamlString("fields"), fields,
+		},
+	}, nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler. The type and key are cleaned as
+// by NewBibEntry, and the fields are added in the order of the mapping.
+func (entry *BibEntry) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("%w: line %d: entry is not a mapping", ErrInvalidField, node.Line)
+	}
+	var typ, key string
+	var fields []*yaml.Node // Names and values.
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name, val := node.Content[i].Value, node.Content[i+1]
+		switch name {
+		case "type":
+			typ = val.Value
+		case "key":
+			key = val.Value
+		case "fields":
+			if val.Kind != yaml.MappingNode {
+				return fmt.Errorf("%w: line %d: fields is not a mapping", ErrInvalidField, val.Line)
+			}
+			fields = val.Content
+		}
+	}
+	decoded := NewBibEntry(typ, key)
+	for j := 0; j+1 < len(fields); j += 2 {
+		decoded.AddField(fields[j].Value, NewBibConst(fields[j+1].Value))
+	}
+	*entry = *decoded
+	return nil
+}
+
+// yamlString returns a YAML string scalar.
+func yamlString(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+}
+
+// ToYAML returns the entries as a YAML sequence of entries (see
+// BibEntry.MarshalYAML), e.g. for the front matter of a static site.
+// Preambles and string variables are not included.
+func (bib *BibTex) ToYAML() ([]byte, error) {
+	return yaml.Marshal(bib.Entries)
+}
+
+// ParseYAML parses a YAML sequence of entries as written by ToYAML.
+func ParseYAML(data []byte) (*BibTex, error) {
+	var entries []*BibEntry
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	bib := NewBibTex()
+	for _, entry := range entries {
+		bib.AddEntry(entry)
+	}
+	return bib, nil
+}
@@ -0,0 +1,65 @@
+//go:build yaml
+// +build yaml
+
+package bibtex
+
+import (
+	"strings"
+	"testing"
+)
+
+// Tests writing and reading entries as YAML.
+func TestYAML(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{acm = {ACM}}
+@article{knuth1984, title = {Literate Programming}, year = 1984, publisher = acm # { Press}}
+@misc{empty,}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := bib.ToYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `- type: article
+  key: knuth1984
+  fields:
+    title: Literate Programming
+    year: "1984"
+    publisher: ACM Press
+- type: misc
+  key: empty
+  fields: {}
+`
+	if string(data) != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, data)
+	}
+	parsed, err := ParseYAML(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.String() != bib.String() {
+		t.Errorf("Expected\n%s\nbut got\n%s", bib.String(), parsed.String())
+	}
+	if _, err := ParseYAML([]byte("- just a string")); err == nil {
+		t.Error("Expected error for an entry that is not a mapping")
+	}
+}
+
+// Tests that entry types read from YAML are lowercased like parsed ones.
+func TestYAMLMixedCaseType(t *testing.T) {
+	bib, err := ParseYAML([]byte(`- type: Article
+  key: knuth1984
+  fields:
+    title: Literate Programming
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := bib.Entries[0]
+	if entry.Type != "article" || entry.TypeSpelling() != "Article" {
+		t.Errorf("Expected type article spelt Article but got %s spelt %s", entry.Type, entry.TypeSpelling())
+	}
+	if entries := bib.EntriesOfType("article"); len(entries) != 1 {
+		t.Errorf("Expected one article but got %d", len(entries))
+	}
+}